@@ -0,0 +1,239 @@
+package betting
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// minEdgeRounds is the number of rounds a true count must have been measured
+// over for its edge to be used, as the estimates of rarer counts are too
+// noisy to size bets on.
+const minEdgeRounds = 1000
+
+// Edge is the measured result of the rounds started at a true count, rounded
+// down, per initial bet.
+type Edge struct {
+	TrueCount     int
+	NumRounds     int
+	AverageResult float64
+	Variance      float64
+}
+
+// kellyStep is the bet at a true count.
+type kellyStep struct {
+	trueCount int
+	bet       int
+}
+
+// Kelly bets a fraction of the Kelly optimal bet, the bankroll times the
+// ratio of the expected result to its variance, at the true count, rounded
+// down. The expected result is taken from a line fitted to the edges measured
+// by true count, weighted by their precision, as the average result of a
+// single count is too noisy to size bets on. Bets are rounded down to a
+// multiple of the chip size, clamped by the table limits and never lower than
+// the bet at a lower true count. The player bets the minimum when the edge is
+// negative.
+type Kelly struct {
+	steps []kellyStep
+	// intercept and slope are the fitted expected result per initial bet by
+	// true count.
+	intercept float64
+	slope     float64
+}
+
+// NewKelly creates a new Kelly from the edges measured by true count. True
+// counts measured over too few rounds are ignored, and true counts outside
+// the range measured use the bet of the nearest true count. A maxBet of 0
+// means there is no table maximum.
+func NewKelly(bankroll int64, fraction float64, edges []Edge, chipSize, minBet, maxBet int) (*Kelly, error) {
+	if bankroll <= 0 {
+		return nil, errors.New("bankroll must be greater than 0")
+	}
+
+	if fraction <= 0 {
+		return nil, errors.New("fraction of the Kelly bet must be greater than 0")
+	}
+
+	if chipSize <= 0 {
+		return nil, errors.New("chip size must be greater than 0")
+	}
+
+	if minBet < 0 || maxBet < 0 {
+		return nil, errors.New("table limits must not be negative")
+	}
+
+	if maxBet > 0 && maxBet < max(minBet, chipSize) {
+		return nil, errors.New("maximum bet must not be less than the minimum bet or the chip size")
+	}
+
+	measured := make([]Edge, 0, len(edges))
+	for _, edge := range edges {
+		if edge.NumRounds >= minEdgeRounds && edge.Variance > 0 {
+			measured = append(measured, edge)
+		}
+	}
+
+	if len(measured) == 0 {
+		return nil, fmt.Errorf("no true count was measured over at least %d rounds", minEdgeRounds)
+	}
+
+	sort.Slice(measured, func(i, j int) bool {
+		return measured[i].TrueCount < measured[j].TrueCount
+	})
+
+	intercept, slope := fitEdges(measured)
+
+	// The player must bet at least one chip
+	lowestBet := max(minBet, chipSize)
+
+	steps := make([]kellyStep, 0, len(measured))
+	for _, edge := range measured {
+		bet := lowestBet
+
+		expectedResult := intercept + slope*float64(edge.TrueCount)
+		if expectedResult > 0 {
+			optimalBet := fraction * float64(bankroll) * expectedResult / edge.Variance
+			bet = max(int(optimalBet)/chipSize*chipSize, lowestBet)
+		}
+		if maxBet > 0 && bet > maxBet {
+			bet = maxBet
+		}
+
+		// The variance also varies by true count, so the ramp is kept
+		// non-decreasing
+		if len(steps) > 0 {
+			bet = max(bet, steps[len(steps)-1].bet)
+		}
+
+		steps = append(steps, kellyStep{trueCount: edge.TrueCount, bet: bet})
+	}
+
+	return &Kelly{steps: steps, intercept: intercept, slope: slope}, nil
+}
+
+// fitEdges fits a line to the average results by true count by weighted least
+// squares, weighting each true count by the inverse of the variance of its
+// average result, and returns its intercept and slope. With a single true
+// count, the line is flat at its average result.
+func fitEdges(edges []Edge) (float64, float64) {
+	var sumWeight, sumX, sumY, sumXX, sumXY float64
+	for _, edge := range edges {
+		weight := float64(edge.NumRounds) / edge.Variance
+		x := float64(edge.TrueCount)
+		sumWeight += weight
+		sumX += weight * x
+		sumY += weight * edge.AverageResult
+		sumXX += weight * x * x
+		sumXY += weight * x * edge.AverageResult
+	}
+
+	denominator := sumWeight*sumXX - sumX*sumX
+	if denominator == 0 {
+		return sumY / sumWeight, 0
+	}
+
+	slope := (sumWeight*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - slope*sumX) / sumWeight
+	return intercept, slope
+}
+
+func (k Kelly) Bet(trueCount float64) int {
+	flooredTrueCount := int(math.Floor(trueCount))
+
+	bet := k.steps[0].bet
+	for _, step := range k.steps {
+		if flooredTrueCount < step.trueCount {
+			break
+		}
+		bet = step.bet
+	}
+
+	return bet
+}
+
+// FittedEdge returns the intercept and slope of the expected result per
+// initial bet fitted by true count.
+func (k Kelly) FittedEdge() (float64, float64) {
+	return k.intercept, k.slope
+}
+
+// Bets returns the bet at each true count measured, keyed by true count.
+func (k Kelly) Bets() map[int]int {
+	bets := make(map[int]int, len(k.steps))
+	for _, step := range k.steps {
+		bets[step.trueCount] = step.bet
+	}
+	return bets
+}
+
+// LoadEdgesFromCSV loads the edges by true count from a CSV file exported by
+// the TrueCountCSVExporter, with the columns true_count, num_rounds,
+// average_result and variance in any order.
+func LoadEdgesFromCSV(filePath string) ([]Edge, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("expected a header row")
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[name] = i
+	}
+
+	for _, name := range []string{"true_count", "num_rounds", "average_result", "variance"} {
+		if _, exists := columns[name]; !exists {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+
+	edges := make([]Edge, 0, len(records)-1)
+
+	// Skip the header row
+	for i, record := range records[1:] {
+		row := i + 2
+
+		trueCount, err := strconv.Atoi(record[columns["true_count"]])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid true_count %q", row, record[columns["true_count"]])
+		}
+
+		numRounds, err := strconv.Atoi(record[columns["num_rounds"]])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid num_rounds %q", row, record[columns["num_rounds"]])
+		}
+
+		averageResult, err := strconv.ParseFloat(record[columns["average_result"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid average_result %q", row, record[columns["average_result"]])
+		}
+
+		variance, err := strconv.ParseFloat(record[columns["variance"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid variance %q", row, record[columns["variance"]])
+		}
+
+		edges = append(edges, Edge{
+			TrueCount:     trueCount,
+			NumRounds:     numRounds,
+			AverageResult: averageResult,
+			Variance:      variance,
+		})
+	}
+
+	return edges, nil
+}
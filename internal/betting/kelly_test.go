@@ -0,0 +1,222 @@
+package betting
+
+import (
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// linearEdges returns edges measured over enough rounds whose average result
+// is exactly intercept + slope * true count, with a variance of 1.
+func linearEdges(minTrueCount, maxTrueCount int, intercept, slope float64) []Edge {
+	var edges []Edge
+	for trueCount := minTrueCount; trueCount <= maxTrueCount; trueCount++ {
+		edges = append(edges, Edge{
+			TrueCount:     trueCount,
+			NumRounds:     minEdgeRounds,
+			AverageResult: intercept + slope*float64(trueCount),
+			Variance:      1,
+		})
+	}
+	return edges
+}
+
+func TestNewKellyBets(t *testing.T) {
+	tests := []struct {
+		name     string
+		edges    []Edge
+		chipSize int
+		minBet   int
+		maxBet   int
+		want     map[int]int
+	}{
+		{
+			// Edges of -0.499%, 0.001%, 0.501%, 1.001% and 1.501% on a
+			// bankroll of 100,000
+			name:     "bet table",
+			edges:    linearEdges(0, 4, -0.00499, 0.005),
+			chipSize: 1,
+			minBet:   10,
+			want:     map[int]int{0: 10, 1: 10, 2: 501, 3: 1001, 4: 1501},
+		},
+		{
+			name:     "rounded down to chips",
+			edges:    linearEdges(2, 2, 0.00123, 0),
+			chipSize: 25,
+			want:     map[int]int{2: 100},
+		},
+		{
+			name:     "clamped by table limits",
+			edges:    linearEdges(-1, 3, 0.00001, 0.01),
+			chipSize: 5,
+			minBet:   50,
+			maxBet:   2000,
+			want:     map[int]int{-1: 50, 0: 50, 1: 1000, 2: 2000, 3: 2000},
+		},
+		{
+			name:     "at least one chip",
+			edges:    linearEdges(0, 0, -0.01, 0),
+			chipSize: 25,
+			minBet:   10,
+			want:     map[int]int{0: 25},
+		},
+		{
+			name: "rare counts ignored",
+			edges: append(linearEdges(0, 1, 0.00001, 0.01), Edge{
+				TrueCount:     2,
+				NumRounds:     minEdgeRounds - 1,
+				AverageResult: 1,
+				Variance:      1,
+			}),
+			chipSize: 1,
+			want:     map[int]int{0: 1, 1: 1001},
+		},
+		{
+			// The average result at +1 is far below the others, but the
+			// fitted edge of -0.0299% +1% per true count keeps the ramp
+			// rising
+			name: "noisy count smoothed",
+			edges: []Edge{
+				{TrueCount: 0, NumRounds: 100000, AverageResult: 0, Variance: 1},
+				{TrueCount: 1, NumRounds: 1000, AverageResult: -0.05, Variance: 1},
+				{TrueCount: 2, NumRounds: 100000, AverageResult: 0.02, Variance: 1},
+			},
+			chipSize: 1,
+			want:     map[int]int{0: 1, 1: 970, 2: 1970},
+		},
+		{
+			// A higher variance at +2 would lower its bet below the bet at +1
+			name: "non-decreasing",
+			edges: []Edge{
+				{TrueCount: 1, NumRounds: minEdgeRounds, AverageResult: 0.01001, Variance: 1},
+				{TrueCount: 2, NumRounds: minEdgeRounds, AverageResult: 0.02001, Variance: 4},
+			},
+			chipSize: 1,
+			want:     map[int]int{1: 1001, 2: 1001},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kelly, err := NewKelly(100000, 1, test.edges, test.chipSize, test.minBet, test.maxBet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bets := kelly.Bets(); !maps.Equal(bets, test.want) {
+				t.Errorf("got bets %v, want %v", bets, test.want)
+			}
+		})
+	}
+}
+
+func TestKellyBetUsesNearestTrueCount(t *testing.T) {
+	kelly, err := NewKelly(100000, 1, linearEdges(-1, 2, 0.00001, 0.01), 1, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		trueCount float64
+		want      int
+	}{
+		{trueCount: -5, want: 10},
+		{trueCount: -0.5, want: 10},
+		{trueCount: 1.9, want: 1001},
+		{trueCount: 2, want: 2001},
+		{trueCount: 10, want: 2001},
+	}
+
+	for _, test := range tests {
+		if bet := kelly.Bet(test.trueCount); bet != test.want {
+			t.Errorf("Bet(%g) = %d, want %d", test.trueCount, bet, test.want)
+		}
+	}
+}
+
+func TestNewKellyErrors(t *testing.T) {
+	edges := linearEdges(0, 2, 0, 0.01)
+
+	tests := []struct {
+		name     string
+		bankroll int64
+		fraction float64
+		edges    []Edge
+		chipSize int
+		minBet   int
+		maxBet   int
+	}{
+		{name: "no bankroll", bankroll: 0, fraction: 1, edges: edges, chipSize: 1},
+		{name: "no fraction", bankroll: 1000, fraction: 0, edges: edges, chipSize: 1},
+		{name: "no chip size", bankroll: 1000, fraction: 1, edges: edges, chipSize: 0},
+		{name: "maximum below minimum", bankroll: 1000, fraction: 1, edges: edges, chipSize: 1, minBet: 10, maxBet: 5},
+		{name: "no edges measured", bankroll: 1000, fraction: 1, edges: []Edge{{TrueCount: 0, NumRounds: 1, Variance: 1}}, chipSize: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewKelly(test.bankroll, test.fraction, test.edges, test.chipSize, test.minBet, test.maxBet); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestKellyFittedEdge(t *testing.T) {
+	kelly, err := NewKelly(100000, 1, linearEdges(-2, 4, -0.005, 0.004), 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intercept, slope := kelly.FittedEdge()
+	if math.Abs(intercept+0.005) > 1e-12 || math.Abs(slope-0.004) > 1e-12 {
+		t.Errorf("got fitted edge %g + %g * true count, want -0.005 + 0.004 * true count", intercept, slope)
+	}
+}
+
+func TestLoadEdgesFromCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []Edge
+		wantErr bool
+	}{
+		{
+			name: "columns in any order",
+			csv:  "variance,true_count,average_result,num_rounds,other\n1.3,-1,-0.01,2000,x\n1.4,2,0.015,500,y\n",
+			want: []Edge{
+				{TrueCount: -1, NumRounds: 2000, AverageResult: -0.01, Variance: 1.3},
+				{TrueCount: 2, NumRounds: 500, AverageResult: 0.015, Variance: 1.4},
+			},
+		},
+		{name: "empty", csv: "", wantErr: true},
+		{name: "missing column", csv: "true_count,num_rounds,average_result\n0,100,0\n", wantErr: true},
+		{name: "invalid true count", csv: "true_count,num_rounds,average_result,variance\n0.5,100,0,1\n", wantErr: true},
+		{name: "invalid variance", csv: "true_count,num_rounds,average_result,variance\n0,100,0,high\n", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "edges.csv")
+			if err := os.WriteFile(filePath, []byte(test.csv), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			edges, err := LoadEdgesFromCSV(filePath)
+			if test.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(edges, test.want) {
+				t.Errorf("got edges %v, want %v", edges, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package betting
+
+// Policy decides the initial bet of each round.
+type Policy interface {
+	// Bet returns the initial bet for a round starting at the given true
+	// count.
+	Bet(trueCount float64) int
+}
@@ -0,0 +1,94 @@
+package betting
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// rampStep is the number of units bet from a true count onwards.
+type rampStep struct {
+	trueCount int
+	units     int
+}
+
+// Ramp is a bet ramp that maps the true count, rounded down, to a number of
+// betting units, clamped by the table limits.
+type Ramp struct {
+	unit   int
+	steps  []rampStep
+	minBet int
+	maxBet int
+}
+
+// NewRamp creates a new Ramp. The player bets 1 unit below the lowest true
+// count in unitsByTrueCount, and the number of units of the highest true
+// count reached otherwise. A maxBet of 0 means there is no table maximum.
+func NewRamp(unit int, unitsByTrueCount map[int]int, minBet, maxBet int) (*Ramp, error) {
+	if unit <= 0 {
+		return nil, errors.New("bet unit must be greater than 0")
+	}
+
+	if minBet < 0 || maxBet < 0 {
+		return nil, errors.New("table limits must not be negative")
+	}
+
+	if maxBet > 0 && maxBet < minBet {
+		return nil, errors.New("maximum bet must not be less than the minimum bet")
+	}
+
+	steps := make([]rampStep, 0, len(unitsByTrueCount))
+	for trueCount, units := range unitsByTrueCount {
+		if units <= 0 {
+			return nil, fmt.Errorf("units at true count %d must be greater than 0", trueCount)
+		}
+		steps = append(steps, rampStep{trueCount: trueCount, units: units})
+	}
+
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].trueCount < steps[j].trueCount
+	})
+
+	return &Ramp{
+		unit:   unit,
+		steps:  steps,
+		minBet: minBet,
+		maxBet: maxBet,
+	}, nil
+}
+
+// NewFlatBet creates a Ramp that always bets a single unit.
+func NewFlatBet(unit int) (*Ramp, error) {
+	return NewRamp(unit, nil, 0, 0)
+}
+
+// Unit returns the size of a betting unit.
+func (r Ramp) Unit() int {
+	return r.unit
+}
+
+func (r Ramp) Bet(trueCount float64) int {
+	flooredTrueCount := int(math.Floor(trueCount))
+
+	units := 1
+	for _, step := range r.steps {
+		if flooredTrueCount < step.trueCount {
+			break
+		}
+		units = step.units
+	}
+
+	return r.clamp(units * r.unit)
+}
+
+// clamp limits the bet to the table minimum and maximum.
+func (r Ramp) clamp(bet int) int {
+	if bet < r.minBet {
+		return r.minBet
+	}
+	if r.maxBet > 0 && bet > r.maxBet {
+		return r.maxBet
+	}
+	return bet
+}
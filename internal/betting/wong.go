@@ -0,0 +1,40 @@
+package betting
+
+import "fmt"
+
+// Wong decides when a back-counter joins and leaves the game. While not
+// seated, the player watches the rounds without betting.
+type Wong struct {
+	entry          float64
+	exit           float64
+	noMidShoeEntry bool
+}
+
+// NewWong creates a new Wong. The player joins when the true count reaches
+// entry and leaves when it falls below exit. With noMidShoeEntry, the player
+// may only join at the start of a shoe, whatever the count, and has to wait
+// for the next shoe after leaving.
+func NewWong(entry, exit float64, noMidShoeEntry bool) (*Wong, error) {
+	if exit > entry {
+		return nil, fmt.Errorf("exit true count %g must not be greater than entry true count %g", exit, entry)
+	}
+
+	return &Wong{
+		entry:          entry,
+		exit:           exit,
+		noMidShoeEntry: noMidShoeEntry,
+	}, nil
+}
+
+// Plays reports whether the player bets on a round starting at the true
+// count, given whether the player was seated in the previous round and
+// whether the round is the first of the shoe.
+func (w Wong) Plays(seated, firstRound bool, trueCount float64) bool {
+	if seated {
+		return trueCount >= w.exit
+	}
+	if w.noMidShoeEntry {
+		return firstRound
+	}
+	return trueCount >= w.entry
+}
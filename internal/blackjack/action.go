@@ -14,6 +14,7 @@ const (
 	Double    Action = 'D'
 	Split     Action = 'P'
 	Surrender Action = 'U'
+	Insurance Action = 'I' // Only used by index tables
 )
 
 func (a Action) String() string {
@@ -1,9 +1,12 @@
 package blackjack
 
 import (
-	_ "embed"
+	"embed"
 	"encoding/csv"
 	"errors"
+	"fmt"
+	"path"
+	"sort"
 	"strings"
 
 	"github.com/jljl1337/blackjack-simulator/internal/core"
@@ -12,6 +15,12 @@ import (
 //go:embed s17.csv
 var s17csv string
 
+// charts are the built-in basic strategy charts by number of decks, dealer
+// rule, double after split and surrender, named as in ChartName.
+//
+//go:embed charts/*.csv
+var charts embed.FS
+
 type BasicStrategy struct {
 	strategyTable map[string]map[string][]Action
 }
@@ -21,10 +30,72 @@ func NewBasicStrategyS17() (*BasicStrategy, error) {
 	return NewBasicStrategyFromCSV(s17csv)
 }
 
+// ChartName returns the name of the built-in chart for the rules, e.g.
+// "4-8d-s17-das-surrender". Charts are for 1, 2 or 4 to 8 decks, and a shoe
+// of 3 or more decks uses the 4 to 8 deck charts.
+func ChartName(numDecks uint, dealerHitsSoft17, doubleAfterSplit, surrender bool) string {
+	decks := "4-8d"
+	switch numDecks {
+	case 1:
+		decks = "1d"
+	case 2:
+		decks = "2d"
+	}
+
+	dealerRule := "s17"
+	if dealerHitsSoft17 {
+		dealerRule = "h17"
+	}
+
+	split := "nodas"
+	if doubleAfterSplit {
+		split = "das"
+	}
+
+	surrenderRule := "nosurrender"
+	if surrender {
+		surrenderRule = "surrender"
+	}
+
+	return fmt.Sprintf("%s-%s-%s-%s", decks, dealerRule, split, surrenderRule)
+}
+
+// ChartNames returns the names of the built-in charts in ascending order.
+func ChartNames() []string {
+	entries, err := charts.ReadDir("charts")
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".csv"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBasicStrategyFromChart creates a new BasicStrategy instance from a
+// built-in chart by name.
+func NewBasicStrategyFromChart(name string) (*BasicStrategy, error) {
+	bytes, err := charts.ReadFile(path.Join("charts", name+".csv"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown chart %q, expected one of %s", name, strings.Join(ChartNames(), ", "))
+	}
+
+	strategy, err := NewBasicStrategyFromCSV(string(bytes))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing chart %s: %w", name, err)
+	}
+	return strategy, nil
+}
+
 // NewBasicStrategyFromCSV creates a new BasicStrategy instance from a CSV string.
 func NewBasicStrategyFromCSV(csvString string) (*BasicStrategy, error) {
 	// Create a CSV reader from the embedded string
 	reader := csv.NewReader(strings.NewReader(csvString))
+	// The number of columns is checked by row to report where it is wrong
+	reader.FieldsPerRecord = -1
 
 	// Read all records at once
 	records, err := reader.ReadAll()
@@ -43,47 +114,96 @@ func NewBasicStrategyFromCSV(csvString string) (*BasicStrategy, error) {
 	}, nil
 }
 
+// dealerUpCards are the columns of a strategy table after the player hand.
+var dealerUpCards = []string{"2", "3", "4", "5", "6", "7", "8", "9", "10", "A"}
+
+// playerHands are the rows of a strategy table.
+var playerHands = []string{
+	"H4", "H5", "H6", "H7", "H8", "H9", "H10", "H11", "H12", "H13", "H14",
+	"H15", "H16", "H17", "H18", "H19", "H20", "H21",
+	"S12", "S13", "S14", "S15", "S16", "S17", "S18", "S19", "S20", "S21",
+	"P2", "P3", "P4", "P5", "P6", "P7", "P8", "P9", "P10", "PA",
+}
+
 // recordsToMapOfMaps converts a 2D slice of strings (CSV records) into a map
-// of maps of Actions.
+// of maps of Actions. The header must be PlayerHand followed by the dealer up
+// cards 2 to 10 and A, and every player hand must have exactly one row. Rows
+// and columns in errors are numbered from 1, counting the header.
 func recordsToMapOfMaps(records [][]string) (map[string]map[string][]Action, error) {
-	if len(records) != 39 {
-		return nil, errors.New("expected 39 rows in the CSV data")
+	if len(records) == 0 {
+		return nil, errors.New("expected a header row")
 	}
 
-	result := make(map[string]map[string][]Action)
 	headers := records[0]
+	numColumns := len(dealerUpCards) + 1
 
-	if len(headers) != 11 {
-		return nil, errors.New("expected 11 columns in the CSV header")
+	if len(headers) != numColumns {
+		return nil, fmt.Errorf("row 1: expected %d columns, got %d", numColumns, len(headers))
 	}
 
+	for j, dealerUpCard := range dealerUpCards {
+		if headers[j+1] != dealerUpCard {
+			return nil, fmt.Errorf("row 1, column %d: expected dealer up card %s, got %q", j+2, dealerUpCard, headers[j+1])
+		}
+	}
+
+	knownHands := make(map[string]bool, len(playerHands))
+	for _, playerHand := range playerHands {
+		knownHands[playerHand] = true
+	}
+
+	result := make(map[string]map[string][]Action)
+
 	// Loop through all rows starting from index 1 to skip header row
 	for i := 1; i < len(records); i++ {
 		record := records[i]
-		if len(record) != 11 {
-			return nil, errors.New("expected 11 columns in each data row")
+		row := i + 1
+
+		if len(record) != numColumns {
+			return nil, fmt.Errorf("row %d: expected %d columns, got %d", row, numColumns, len(record))
+		}
+
+		playerHand := record[0]
+		if !knownHands[playerHand] {
+			return nil, fmt.Errorf("row %d, column 1: unknown player hand %q", row, playerHand)
+		}
+		if _, exists := result[playerHand]; exists {
+			return nil, fmt.Errorf("row %d, column 1: duplicate player hand %s", row, playerHand)
 		}
 
 		rowMap := make(map[string][]Action)
 
 		// Loop through each column in the row, starting from index 1
 		for j := 1; j < len(headers); j++ {
+			if record[j] == "" {
+				return nil, fmt.Errorf("row %d (%s), column %d (%s): missing action", row, playerHand, j+1, headers[j])
+			}
+
 			var actions, err = StringToActions(record[j])
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("row %d (%s), column %d (%s): %w", row, playerHand, j+1, headers[j], err)
 			}
 
 			// Use the header as the key
 			rowMap[headers[j]] = actions
 		}
 
-		result[record[0]] = rowMap
+		result[playerHand] = rowMap
+	}
+
+	for _, playerHand := range playerHands {
+		if _, exists := result[playerHand]; !exists {
+			return nil, fmt.Errorf("missing row for player hand %s", playerHand)
+		}
 	}
 
 	return result, nil
 }
 
-func (bs BasicStrategy) GetActions(
+// Actions returns the actions of the chart for the hand against the dealer
+// up card in order of preference, those of the pair row first if the hand is
+// a pair.
+func (bs BasicStrategy) Actions(
 	playerHand core.Hand,
 	dealerUpCard core.Card,
 ) ([]Action, error) {
@@ -95,12 +215,20 @@ func (bs BasicStrategy) GetActions(
 			return nil, err
 		}
 
-		actions = bs.strategyTable[pairString][dealerUpCard.ValueString()]
+		actions = append(actions, bs.strategyTable[pairString][dealerUpCard.ValueString()]...)
 	}
 
 	actions = append(actions, bs.strategyTable[playerHand.ValueString()][dealerUpCard.ValueString()]...)
 
-	actions = append(actions, Stand) // For no hit, split or double after split ace
-
 	return actions, nil
 }
+
+// Decide returns the first action of the chart that is allowed.
+func (bs BasicStrategy) Decide(decision DecisionContext) (Action, error) {
+	actions, err := bs.Actions(decision.PlayerHand, decision.DealerUpCard)
+	if err != nil {
+		return NA, err
+	}
+
+	return decideFromChart(actions, decision)
+}
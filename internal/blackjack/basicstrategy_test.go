@@ -0,0 +1,82 @@
+package blackjack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewBasicStrategyFromCSV(t *testing.T) {
+	// Rows may be in any order
+	lines := strings.Split(strings.TrimSpace(s17csv), "\n")
+	lines[1], lines[len(lines)-1] = lines[len(lines)-1], lines[1]
+
+	if _, err := NewBasicStrategyFromCSV(strings.Join(lines, "\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewBasicStrategyFromCSVErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		wantErr string
+	}{
+		{
+			name:    "empty",
+			csv:     "",
+			wantErr: "expected a header row",
+		},
+		{
+			name:    "header columns",
+			csv:     strings.Replace(s17csv, "10,A\n", "10\n", 1),
+			wantErr: "row 1: expected 11 columns, got 10",
+		},
+		{
+			name:    "header up card",
+			csv:     strings.Replace(s17csv, "10,A\n", "T,A\n", 1),
+			wantErr: `row 1, column 10: expected dealer up card 10, got "T"`,
+		},
+		{
+			name:    "row columns",
+			csv:     strings.Replace(s17csv, "H4,H,H,H,H,H,H,H,H,H,H\n", "H4,H,H,H,H,H,H,H,H,H\n", 1),
+			wantErr: "row 2: expected 11 columns, got 10",
+		},
+		{
+			name:    "unknown player hand",
+			csv:     strings.Replace(s17csv, "H4,", "H3,", 1),
+			wantErr: `row 2, column 1: unknown player hand "H3"`,
+		},
+		{
+			name:    "duplicate player hand",
+			csv:     strings.Replace(s17csv, "H5,", "H4,", 1),
+			wantErr: "row 3, column 1: duplicate player hand H4",
+		},
+		{
+			name:    "missing player hand",
+			csv:     strings.Replace(s17csv, "H4,H,H,H,H,H,H,H,H,H,H\n", "", 1),
+			wantErr: "missing row for player hand H4",
+		},
+		{
+			name:    "missing action",
+			csv:     strings.Replace(s17csv, "H4,H,", "H4,,", 1),
+			wantErr: "row 2 (H4), column 2 (2): missing action",
+		},
+		{
+			name:    "invalid action",
+			csv:     strings.Replace(s17csv, "H4,H,", "H4,X,", 1),
+			wantErr: "row 2 (H4), column 2 (2): ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewBasicStrategyFromCSV(test.csv)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got error %q, want it to contain %q", err, test.wantErr)
+			}
+		})
+	}
+}
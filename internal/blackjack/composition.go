@@ -0,0 +1,224 @@
+package blackjack
+
+import (
+	"encoding/csv"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+)
+
+// compositionRule overrides the total-dependent actions of a hand against
+// some dealer up cards when the hand matches all of its conditions.
+type compositionRule struct {
+	name string
+	// playerHand is the total or pair the rule applies to, e.g. H16 or P8.
+	playerHand string
+	// cards are the sorted card values of the hand if they must match
+	// exactly, e.g. ["10", "6"].
+	cards []string
+	// minCards and maxCards bound the number of cards, 0 for no bound.
+	minCards int
+	maxCards int
+	// fromSplit requires the hand to come from a split if true, or not to
+	// if false, and does not matter if nil.
+	fromSplit *bool
+	// actions are the actions by dealer up card. Up cards without actions
+	// are not overridden.
+	actions map[string][]Action
+}
+
+func (r compositionRule) matches(hand core.Hand, fromSplit bool) bool {
+	pairString, _ := hand.PairString()
+	if r.playerHand != hand.ValueString() && r.playerHand != pairString {
+		return false
+	}
+
+	numCards := len(hand.Cards())
+	if r.minCards > 0 && numCards < r.minCards {
+		return false
+	}
+	if r.maxCards > 0 && numCards > r.maxCards {
+		return false
+	}
+
+	if r.fromSplit != nil && *r.fromSplit != fromSplit {
+		return false
+	}
+
+	if r.cards != nil {
+		return slices.Equal(r.cards, sortedCardValues(hand.Cards()))
+	}
+
+	return true
+}
+
+// CompositionStrategy is a basic strategy with rules that depend on the
+// cards in the hand, the number of cards and whether the hand came from a
+// split, on top of a total-dependent BasicStrategy.
+type CompositionStrategy struct {
+	totalDependent *BasicStrategy
+	rules          []compositionRule
+}
+
+// NewCompositionStrategyFromCSV creates a new CompositionStrategy from a CSV
+// string in the format of NewBasicStrategyFromCSV followed by composition
+// rules. The player hand of a rule is a total or pair and its conditions
+// separated by colons, e.g. "H16:3+" for 3 or more cards, "H16:2" for
+// exactly 2 cards, "H16:10-6" for the exact cards in any order, and
+// "P8:split" or "P8:nosplit" for whether the hand came from a split. Cells
+// left empty keep the total-dependent actions.
+func NewCompositionStrategyFromCSV(csvString string) (*CompositionStrategy, error) {
+	reader := csv.NewReader(strings.NewReader(csvString))
+	// The number of columns is checked by row to report where it is wrong
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// The composition rules follow the rows of the total-dependent table
+	numTotalRows := len(records)
+	for i, record := range records {
+		if i > 0 && len(record) > 0 && strings.Contains(record[0], ":") {
+			numTotalRows = i
+			break
+		}
+	}
+
+	strategyMap, err := recordsToMapOfMaps(records[:numTotalRows])
+	if err != nil {
+		return nil, err
+	}
+
+	headers := records[0]
+	numColumns := len(dealerUpCards) + 1
+
+	rules := make([]compositionRule, 0, len(records)-numTotalRows)
+	for i := numTotalRows; i < len(records); i++ {
+		record := records[i]
+		row := i + 1
+
+		if len(record) != numColumns {
+			return nil, fmt.Errorf("row %d: expected %d columns, got %d", row, numColumns, len(record))
+		}
+
+		rule, err := parseCompositionRule(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("row %d, column 1: %w", row, err)
+		}
+
+		rule.actions = make(map[string][]Action)
+		for j := 1; j < len(headers); j++ {
+			if record[j] == "" {
+				continue
+			}
+
+			actions, err := StringToActions(record[j])
+			if err != nil {
+				return nil, fmt.Errorf("row %d (%s), column %d (%s): %w", row, rule.name, j+1, headers[j], err)
+			}
+			rule.actions[headers[j]] = actions
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return &CompositionStrategy{
+		totalDependent: &BasicStrategy{strategyTable: strategyMap},
+		rules:          rules,
+	}, nil
+}
+
+// parseCompositionRule parses the player hand and conditions of a
+// composition rule, e.g. "H16:3+:nosplit".
+func parseCompositionRule(name string) (compositionRule, error) {
+	parts := strings.Split(name, ":")
+	rule := compositionRule{name: name, playerHand: parts[0]}
+
+	if !slices.Contains(playerHands, rule.playerHand) {
+		return compositionRule{}, fmt.Errorf("unknown player hand %q", rule.playerHand)
+	}
+
+	for _, condition := range parts[1:] {
+		switch {
+		case condition == "split" || condition == "nosplit":
+			fromSplit := condition == "split"
+			rule.fromSplit = &fromSplit
+
+		case strings.Contains(condition, "-"):
+			cards := strings.Split(condition, "-")
+			for _, card := range cards {
+				if !slices.Contains(dealerUpCards, card) {
+					return compositionRule{}, fmt.Errorf("invalid card %q in condition %q, expected 2 to 10 or A", card, condition)
+				}
+			}
+			slices.Sort(cards)
+			rule.cards = cards
+
+		case strings.HasSuffix(condition, "+"):
+			minCards, err := strconv.Atoi(strings.TrimSuffix(condition, "+"))
+			if err != nil || minCards < 2 {
+				return compositionRule{}, fmt.Errorf("invalid number of cards in condition %q", condition)
+			}
+			rule.minCards = minCards
+
+		default:
+			numCards, err := strconv.Atoi(condition)
+			if err != nil || numCards < 2 {
+				return compositionRule{}, fmt.Errorf("invalid condition %q, expected split, nosplit, a number of cards such as 3 or 3+, or cards such as 10-6", condition)
+			}
+			rule.minCards = numCards
+			rule.maxCards = numCards
+		}
+	}
+
+	return rule, nil
+}
+
+// sortedCardValues returns the values of the cards as in strategy charts,
+// sorted as strings.
+func sortedCardValues(cards []core.Card) []string {
+	values := make([]string, 0, len(cards))
+	for _, card := range cards {
+		values = append(values, card.ValueString())
+	}
+	slices.Sort(values)
+	return values
+}
+
+// TotalDependent returns the total-dependent strategy the rules are applied
+// on top of.
+func (cs CompositionStrategy) TotalDependent() *BasicStrategy {
+	return cs.totalDependent
+}
+
+// NumRules returns the number of composition rules.
+func (cs CompositionStrategy) NumRules() int {
+	return len(cs.rules)
+}
+
+// Decide returns the first allowed action of the first rule in file order
+// that matches the hand and has actions against the dealer up card, followed
+// by the total-dependent actions as fallbacks.
+func (cs CompositionStrategy) Decide(decision DecisionContext) (Action, error) {
+	actions, err := cs.totalDependent.Actions(decision.PlayerHand, decision.DealerUpCard)
+	if err != nil {
+		return NA, err
+	}
+
+	for _, rule := range cs.rules {
+		ruleActions, exists := rule.actions[decision.DealerUpCard.ValueString()]
+		if !exists || !rule.matches(decision.PlayerHand, decision.FromSplit) {
+			continue
+		}
+
+		actions = append(slices.Clone(ruleActions), actions...)
+		break
+	}
+
+	return decideFromChart(actions, decision)
+}
@@ -0,0 +1,168 @@
+package blackjack
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+)
+
+// testHand is a hand of cards for deciding without a player.
+type testHand []core.Card
+
+func handOf(ranks ...core.Rank) testHand {
+	hand := make(testHand, 0, len(ranks))
+	for _, rank := range ranks {
+		hand = append(hand, core.Card{Suit: core.Spades, Rank: rank})
+	}
+	return hand
+}
+
+func (h testHand) hardValue() (int, bool) {
+	value := 0
+	hasAce := false
+	for _, card := range h {
+		lowValue, _ := card.Values()
+		value += lowValue
+		hasAce = hasAce || card.Rank == core.Ace
+	}
+	return value, hasAce
+}
+
+func (h testHand) ValueString() string {
+	if h.IsSoft() {
+		return fmt.Sprintf("S%d", h.Value())
+	}
+	return fmt.Sprintf("H%d", h.Value())
+}
+
+func (h testHand) PairString() (string, error) {
+	if !h.IsPair() {
+		return "", errors.New("hand is not a pair")
+	}
+	return "P" + h[0].ValueString(), nil
+}
+
+func (h testHand) Value() int {
+	value, _ := h.hardValue()
+	if h.IsSoft() {
+		value += 10
+	}
+	return value
+}
+
+func (h testHand) IsBlackjack() bool { return len(h) == 2 && h.Value() == 21 }
+
+func (h testHand) IsSoft() bool {
+	value, hasAce := h.hardValue()
+	return hasAce && value+10 <= 21
+}
+
+func (h testHand) IsBusted() bool { return h.Value() > 21 }
+
+func (h testHand) IsPair() bool { return len(h) == 2 && h[0].Rank == h[1].Rank }
+
+func (h testHand) Cards() []core.Card { return h }
+
+// allActions allows every action, as on the first two cards.
+var allActions = map[Action]bool{Hit: true, Stand: true, Double: true, Split: true, Surrender: true}
+
+// hitOrStand allows the actions after the first two cards.
+var hitOrStand = map[Action]bool{Hit: true, Stand: true}
+
+// compositionRow returns a composition rule row with the actions by dealer up
+// card, leaving the other up cards empty.
+func compositionRow(playerHand string, actions map[string]string) string {
+	row := []string{playerHand}
+	for _, upCard := range dealerUpCards {
+		row = append(row, actions[upCard])
+	}
+	return strings.Join(row, ",") + "\n"
+}
+
+func TestCompositionStrategyDecide(t *testing.T) {
+	csv := s17csv +
+		compositionRow("H16:3+", map[string]string{"10": "S"}) +
+		compositionRow("H16:4+", map[string]string{"9": "S", "10": "H"}) +
+		compositionRow("H12:10-2", map[string]string{"4": "H"}) +
+		compositionRow("P8:split", map[string]string{"A": "H"})
+
+	strategy, err := NewCompositionStrategyFromCSV(csv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strategy.NumRules() != 4 {
+		t.Errorf("got %d rules, want 4", strategy.NumRules())
+	}
+
+	tests := []struct {
+		name      string
+		hand      testHand
+		upCard    core.Rank
+		fromSplit bool
+		want      Action
+	}{
+		{name: "3-card 16 vs 10", hand: handOf(core.Ten, core.Four, core.Two), upCard: core.Ten, want: Stand},
+		{name: "2-card 16 vs 10", hand: handOf(core.Ten, core.Six), upCard: core.Ten, want: Surrender},
+		{name: "first rule in file order", hand: handOf(core.Ten, core.Two, core.Two, core.Two), upCard: core.Ten, want: Stand},
+		{name: "next rule for the up card", hand: handOf(core.Ten, core.Two, core.Two, core.Two), upCard: core.Nine, want: Stand},
+		{name: "no rule for the up card", hand: handOf(core.Ten, core.Four, core.Two), upCard: core.Nine, want: Hit},
+		{name: "exact cards", hand: handOf(core.Two, core.Ten), upCard: core.Four, want: Hit},
+		{name: "other cards", hand: handOf(core.Nine, core.Three), upCard: core.Four, want: Stand},
+		{name: "pair from split", hand: handOf(core.Eight, core.Eight), upCard: core.Ace, fromSplit: true, want: Hit},
+		{name: "pair not from split", hand: handOf(core.Eight, core.Eight), upCard: core.Ace, want: Split},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actionsAllowed := allActions
+			if len(test.hand) > 2 {
+				actionsAllowed = hitOrStand
+			}
+
+			decision := DecisionContext{
+				PlayerHand:     test.hand,
+				DealerUpCard:   core.Card{Suit: core.Hearts, Rank: test.upCard},
+				ActionsAllowed: actionsAllowed,
+				FromSplit:      test.fromSplit,
+			}
+
+			action, err := strategy.Decide(decision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action != test.want {
+				t.Errorf("got action %s, want %s", action, test.want)
+			}
+		})
+	}
+}
+
+func TestNewCompositionStrategyFromCSVErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		row     string
+		wantErr string
+	}{
+		{name: "unknown player hand", row: compositionRow("H3:3+", nil), wantErr: `unknown player hand "H3"`},
+		{name: "invalid card", row: compositionRow("H16:11-5", nil), wantErr: `invalid card "11"`},
+		{name: "too few cards", row: compositionRow("H16:1+", nil), wantErr: `invalid number of cards in condition "1+"`},
+		{name: "invalid condition", row: compositionRow("H16:soft", nil), wantErr: `invalid condition "soft"`},
+		{name: "columns", row: "H16:3+,S\n", wantErr: "row 40: expected 11 columns, got 2"},
+		{name: "invalid action", row: compositionRow("H16:3+", map[string]string{"10": "X"}), wantErr: "row 40 (H16:3+), column 10 (10): "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewCompositionStrategyFromCSV(s17csv + test.row)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("got error %q, want it to contain %q", err, test.wantErr)
+			}
+		})
+	}
+}
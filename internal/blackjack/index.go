@@ -0,0 +1,243 @@
+package blackjack
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+)
+
+//go:embed illustrious18.csv
+var illustrious18csv string
+
+//go:embed fab4.csv
+var fab4csv string
+
+// insuranceKey is the player hand of an index row for the insurance decision.
+const insuranceKey = "Insurance"
+
+// Index is a playing deviation that overrides the basic strategy for a player
+// hand against a dealer up card when the true count crosses a threshold.
+type Index struct {
+	PlayerHand   string
+	DealerUpCard string
+	// Actions are tried before the basic strategy actions when the index
+	// applies.
+	Actions   []Action
+	operator  string
+	threshold float64
+}
+
+// NewIndex creates an index from a true count condition such as ">=0".
+func NewIndex(playerHand, dealerUpCard, condition string, actions []Action) (Index, error) {
+	operator, threshold, err := parseCondition(condition)
+	if err != nil {
+		return Index{}, err
+	}
+
+	return Index{
+		PlayerHand:   playerHand,
+		DealerUpCard: dealerUpCard,
+		Actions:      actions,
+		operator:     operator,
+		threshold:    threshold,
+	}, nil
+}
+
+// Name returns a short name of the index, e.g. "H16vs10".
+func (i Index) Name() string {
+	if i.PlayerHand == insuranceKey {
+		return insuranceKey
+	}
+	return fmt.Sprintf("%svs%s", i.PlayerHand, i.DealerUpCard)
+}
+
+// Applies reports whether the true count satisfies the index's condition.
+func (i Index) Applies(trueCount float64) bool {
+	switch i.operator {
+	case ">=":
+		return trueCount >= i.threshold
+	case ">":
+		return trueCount > i.threshold
+	case "<=":
+		return trueCount <= i.threshold
+	default:
+		return trueCount < i.threshold
+	}
+}
+
+// Condition returns the condition of the index, e.g. ">=0".
+func (i Index) Condition() string {
+	return i.operator + strconv.FormatFloat(i.threshold, 'f', -1, 64)
+}
+
+// IndexTable is a set of playing deviations. When several indexes apply to a
+// decision, the first one whose actions are allowed is used.
+type IndexTable struct {
+	indexes []Index
+}
+
+// NewIndexTable creates an index table from built-in tables by name, either
+// "illustrious18" or "fab4", followed by the tables from CSV files.
+func NewIndexTable(names []string, filePaths []string) (*IndexTable, error) {
+	table := &IndexTable{}
+
+	for _, name := range names {
+		var csvString string
+		switch name {
+		case "illustrious18":
+			csvString = illustrious18csv
+		case "fab4":
+			csvString = fab4csv
+		default:
+			return nil, fmt.Errorf("unknown index table: %s", name)
+		}
+
+		indexes, err := parseIndexCSV(csvString)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing index table %s: %w", name, err)
+		}
+		table.indexes = append(table.indexes, indexes...)
+	}
+
+	for _, filePath := range filePaths {
+		bytes, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+
+		indexes, err := parseIndexCSV(string(bytes))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing index table %s: %w", filePath, err)
+		}
+		table.indexes = append(table.indexes, indexes...)
+	}
+
+	return table, nil
+}
+
+// parseIndexCSV parses an index table with the columns PlayerHand,
+// DealerUpCard, TrueCount and Actions, e.g. "H16,10,>=0,S".
+func parseIndexCSV(csvString string) ([]Index, error) {
+	reader := csv.NewReader(strings.NewReader(csvString))
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 || len(records[0]) != 4 {
+		return nil, fmt.Errorf("expected a header with 4 columns")
+	}
+
+	indexes := make([]Index, 0, len(records)-1)
+
+	// Skip the header row
+	for i, record := range records[1:] {
+		row := i + 2
+
+		if len(record) != 4 {
+			return nil, fmt.Errorf("row %d: expected 4 columns", row)
+		}
+
+		operator, threshold, err := parseCondition(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+
+		var actions []Action
+		if record[0] == insuranceKey {
+			if record[3] != string(Insurance) {
+				return nil, fmt.Errorf("row %d: expected insurance action %s", row, Insurance)
+			}
+			actions = []Action{Insurance}
+		} else {
+			actions, err = StringToActions(record[3])
+			if err != nil {
+				return nil, fmt.Errorf("row %d: %w", row, err)
+			}
+		}
+
+		indexes = append(indexes, Index{
+			PlayerHand:   record[0],
+			DealerUpCard: record[1],
+			Actions:      actions,
+			operator:     operator,
+			threshold:    threshold,
+		})
+	}
+
+	return indexes, nil
+}
+
+// parseCondition parses a true count condition such as ">=0" or "<-1".
+func parseCondition(condition string) (string, float64, error) {
+	for _, operator := range []string{">=", "<=", ">", "<"} {
+		if value, found := strings.CutPrefix(condition, operator); found {
+			threshold, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid true count in condition %q", condition)
+			}
+			return operator, threshold, nil
+		}
+	}
+	return "", 0, fmt.Errorf("invalid condition %q, expected one of >=, >, <= or < followed by a true count", condition)
+}
+
+// Lookup returns the indexes that apply to the player hand against the dealer
+// up card at the true count in table order, with the pair rows first. When the
+// player would split the pair, only the pair rows apply, as the total rows are
+// for playing the hand without splitting.
+func (t IndexTable) Lookup(playerHand core.Hand, dealerUpCard core.Card, trueCount float64, splitting bool) []Index {
+	keys := []string{playerHand.ValueString()}
+	if playerHand.IsPair() {
+		pairString, err := playerHand.PairString()
+		if err == nil {
+			keys = []string{pairString}
+			if !splitting {
+				keys = append(keys, playerHand.ValueString())
+			}
+		}
+	}
+
+	var indexes []Index
+	for _, key := range keys {
+		for _, index := range t.indexes {
+			if index.PlayerHand == key && index.DealerUpCard == dealerUpCard.ValueString() && index.Applies(trueCount) {
+				indexes = append(indexes, index)
+			}
+		}
+	}
+
+	return indexes
+}
+
+// LookupInsurance returns the insurance index if it applies at the true count.
+func (t IndexTable) LookupInsurance(trueCount float64) (Index, bool) {
+	for _, index := range t.indexes {
+		if index.PlayerHand == insuranceKey && index.Applies(trueCount) {
+			return index, true
+		}
+	}
+	return Index{}, false
+}
+
+// HasInsurance reports whether the table has an insurance index, which then
+// replaces the insurance policy.
+func (t IndexTable) HasInsurance() bool {
+	for _, index := range t.indexes {
+		if index.PlayerHand == insuranceKey {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of indexes in the table.
+func (t IndexTable) Len() int {
+	return len(t.indexes)
+}
@@ -0,0 +1,84 @@
+package blackjack
+
+import (
+	"fmt"
+
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+)
+
+// InsurancePolicy decides whether the player takes insurance, or even money
+// for a natural blackjack, when the dealer shows an ace.
+type InsurancePolicy interface {
+	// TakeInsurance reports whether the player takes insurance given the
+	// player's hand, the cards the player has not seen yet and the true count
+	// of the player's counting system.
+	TakeInsurance(playerHand core.Hand, unseenCards, unseenTenValueCards int, trueCount float64) bool
+}
+
+// NeverInsurance never takes insurance or even money.
+type NeverInsurance struct{}
+
+func (NeverInsurance) TakeInsurance(core.Hand, int, int, float64) bool {
+	return false
+}
+
+// AlwaysInsurance always takes insurance or even money.
+type AlwaysInsurance struct{}
+
+func (AlwaysInsurance) TakeInsurance(core.Hand, int, int, float64) bool {
+	return true
+}
+
+// TrueCountInsurance takes insurance when the true count of the player's
+// counting system is at least the threshold, e.g. 3 for Hi-Lo.
+type TrueCountInsurance struct {
+	Threshold float64
+}
+
+func (tc TrueCountInsurance) TakeInsurance(_ core.Hand, _, _ int, trueCount float64) bool {
+	return trueCount >= tc.Threshold
+}
+
+// PerfectTenDensityInsurance takes insurance when the proportion of
+// ten-value cards among the unseen cards exceeds the threshold. A counter
+// cannot know it exactly, so it is the upper bound of what any count can
+// gain from insurance. Insurance paying 2:1 breaks even at a threshold of
+// 1/3.
+type PerfectTenDensityInsurance struct {
+	Threshold float64
+}
+
+func (ptd PerfectTenDensityInsurance) TakeInsurance(_ core.Hand, unseenCards, unseenTenValueCards int, _ float64) bool {
+	if unseenCards <= 0 {
+		return false
+	}
+	return float64(unseenTenValueCards)/float64(unseenCards) > ptd.Threshold
+}
+
+// DefaultInsuranceThreshold returns the threshold of the insurance policy
+// when none is configured, a true count of 3 for "count", the Hi-Lo index,
+// and the break-even ten density of 1/3 for "perfectTenDensity".
+func DefaultInsuranceThreshold(name string) float64 {
+	if name == "perfectTenDensity" {
+		return 1.0 / 3.0
+	}
+	return 3
+}
+
+// NewInsurancePolicy creates an insurance policy by name, one of "never",
+// "always", "count" or "perfectTenDensity". The threshold is only used by
+// the "count" and "perfectTenDensity" policies.
+func NewInsurancePolicy(name string, threshold float64) (InsurancePolicy, error) {
+	switch name {
+	case "never":
+		return NeverInsurance{}, nil
+	case "always":
+		return AlwaysInsurance{}, nil
+	case "count":
+		return TrueCountInsurance{Threshold: threshold}, nil
+	case "perfectTenDensity":
+		return PerfectTenDensityInsurance{Threshold: threshold}, nil
+	default:
+		return nil, fmt.Errorf("invalid insurance policy: %s", name)
+	}
+}
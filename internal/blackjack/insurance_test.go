@@ -0,0 +1,50 @@
+package blackjack
+
+import "testing"
+
+func TestInsurancePolicies(t *testing.T) {
+	tests := []struct {
+		name                string
+		policy              string
+		threshold           float64
+		unseenCards         int
+		unseenTenValueCards int
+		trueCount           float64
+		want                bool
+	}{
+		{name: "never", policy: "never", unseenCards: 52, unseenTenValueCards: 30, trueCount: 10, want: false},
+		{name: "always", policy: "always", unseenCards: 52, unseenTenValueCards: 0, trueCount: -10, want: true},
+		{name: "count at the index", policy: "count", threshold: 3, unseenCards: 52, unseenTenValueCards: 16, trueCount: 3, want: true},
+		{name: "count below the index", policy: "count", threshold: 3, unseenCards: 52, unseenTenValueCards: 30, trueCount: 2.9, want: false},
+		{name: "ten density above break-even", policy: "perfectTenDensity", threshold: 1.0 / 3.0, unseenCards: 30, unseenTenValueCards: 11, trueCount: -5, want: true},
+		{name: "ten density at break-even", policy: "perfectTenDensity", threshold: 1.0 / 3.0, unseenCards: 30, unseenTenValueCards: 10, trueCount: 5, want: false},
+		{name: "ten density without cards", policy: "perfectTenDensity", threshold: 1.0 / 3.0, unseenCards: 0, unseenTenValueCards: 0, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := NewInsurancePolicy(test.policy, test.threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := policy.TakeInsurance(nil, test.unseenCards, test.unseenTenValueCards, test.trueCount); got != test.want {
+				t.Errorf("TakeInsurance() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDefaultInsuranceThreshold(t *testing.T) {
+	if threshold := DefaultInsuranceThreshold("count"); threshold != 3 {
+		t.Errorf("count: got %g, want 3", threshold)
+	}
+	if threshold := DefaultInsuranceThreshold("perfectTenDensity"); threshold != 1.0/3.0 {
+		t.Errorf("perfectTenDensity: got %g, want 1/3", threshold)
+	}
+}
+
+func TestNewInsurancePolicyInvalid(t *testing.T) {
+	if _, err := NewInsurancePolicy("sometimes", 0); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,70 @@
+package blackjack
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Payout is a ratio of the amount won (or lost) to the amount wagered, e.g.
+// 3:2 for a traditional blackjack payout.
+type Payout struct {
+	Numerator   int
+	Denominator int
+}
+
+var (
+	EvenMoney  = Payout{Numerator: 1, Denominator: 1}
+	ThreeToTwo = Payout{Numerator: 3, Denominator: 2}
+	HalfBet    = Payout{Numerator: 1, Denominator: 2}
+	TwoToOne   = Payout{Numerator: 2, Denominator: 1}
+)
+
+// ParsePayout parses a payout ratio in the form "N:D", e.g. "6:5".
+func ParsePayout(payoutString string) (Payout, error) {
+	parts := strings.Split(payoutString, ":")
+	if len(parts) != 2 {
+		return Payout{}, fmt.Errorf("invalid payout %q, expected the form N:D", payoutString)
+	}
+
+	numerator, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || numerator < 0 {
+		return Payout{}, fmt.Errorf("invalid payout numerator in %q", payoutString)
+	}
+
+	denominator, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || denominator <= 0 {
+		return Payout{}, fmt.Errorf("invalid payout denominator in %q", payoutString)
+	}
+
+	return Payout{Numerator: numerator, Denominator: denominator}, nil
+}
+
+// IsZero reports whether the payout is unset.
+func (p Payout) IsZero() bool {
+	return p.Denominator == 0
+}
+
+// Win returns the amount won on the given wager. Bets are settled in whole
+// units and the house keeps any fraction of a unit, so the amount won is
+// rounded down, e.g. 6:5 on a wager of 7 wins 8.
+func (p Payout) Win(amount int) int {
+	if p.IsZero() {
+		return 0
+	}
+	return amount * p.Numerator / p.Denominator
+}
+
+// Loss returns the amount lost on the given wager. As with Win, the house
+// keeps any fraction of a unit, so the amount lost is rounded up, e.g. a
+// surrender of a wager of 5 loses 3.
+func (p Payout) Loss(amount int) int {
+	if p.IsZero() {
+		return 0
+	}
+	return (amount*p.Numerator + p.Denominator - 1) / p.Denominator
+}
+
+func (p Payout) String() string {
+	return fmt.Sprintf("%d:%d", p.Numerator, p.Denominator)
+}
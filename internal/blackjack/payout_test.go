@@ -0,0 +1,32 @@
+package blackjack
+
+import "testing"
+
+func TestPayoutRoundsForTheHouse(t *testing.T) {
+	sixToFive := Payout{Numerator: 6, Denominator: 5}
+
+	tests := []struct {
+		name   string
+		payout Payout
+		amount int
+		win    int
+		loss   int
+	}{
+		{name: "6:5 on 10", payout: sixToFive, amount: 10, win: 12, loss: 12},
+		{name: "6:5 on 7", payout: sixToFive, amount: 7, win: 8, loss: 9},
+		{name: "half of 10", payout: HalfBet, amount: 10, win: 5, loss: 5},
+		{name: "half of 5", payout: HalfBet, amount: 5, win: 2, loss: 3},
+		{name: "unset", payout: Payout{}, amount: 5, win: 0, loss: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if win := test.payout.Win(test.amount); win != test.win {
+				t.Errorf("Win(%d) = %d, want %d", test.amount, win, test.win)
+			}
+			if loss := test.payout.Loss(test.amount); loss != test.loss {
+				t.Errorf("Loss(%d) = %d, want %d", test.amount, loss, test.loss)
+			}
+		})
+	}
+}
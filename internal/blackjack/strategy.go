@@ -1,9 +1,65 @@
 package blackjack
 
 import (
+	"fmt"
+
 	"github.com/jljl1337/blackjack-simulator/internal/core"
 )
 
+// ShoeState is what the player knows about the cards left to be dealt.
+type ShoeState struct {
+	// UnseenCards is the number of cards of each rank the player has not
+	// seen, those in the shoe and the dealer's hole card, indexed by rank.
+	UnseenCards  [core.King + 1]int
+	RunningCount float64
+	TrueCount    float64
+}
+
+// DecisionContext is what the player knows when deciding how to play a hand.
+type DecisionContext struct {
+	PlayerHand   core.Hand
+	DealerUpCard core.Card
+	// ActionsAllowed are the legal actions on the hand.
+	ActionsAllowed map[Action]bool
+	// NumHands is the number of hands the player has after splitting.
+	NumHands int
+	// FromSplit reports whether the hand was formed by splitting.
+	FromSplit bool
+	// SplitAce reports whether the hand was formed by splitting aces.
+	SplitAce bool
+
+	ShoeState
+}
+
 type Strategy interface {
-	GetActions(playerHand core.Hand, dealerUpCard core.Card) ([]Action, error)
+	// Decide returns the action to take on the hand, which must be one of
+	// the actions allowed.
+	Decide(decision DecisionContext) (Action, error)
+}
+
+// SelectAction returns the first action in order of preference that is
+// allowed, or NA if none of them is.
+func SelectAction(actions []Action, actionsAllowed map[Action]bool) Action {
+	for _, action := range actions {
+		if actionsAllowed[action] {
+			return action
+		}
+	}
+	return NA
+}
+
+// decideFromChart returns the first allowed action of a strategy chart cell
+// and its fallbacks. Cells end in hit or stand, so none of the actions is
+// allowed only when hitting is not, e.g. on split aces, and the player
+// stands.
+func decideFromChart(actions []Action, decision DecisionContext) (Action, error) {
+	action := SelectAction(actions, decision.ActionsAllowed)
+	if action == NA && !decision.ActionsAllowed[Hit] && decision.ActionsAllowed[Stand] {
+		action = Stand
+	}
+
+	if action == NA {
+		return NA, fmt.Errorf("none of the actions %v is allowed", actions)
+	}
+	return action, nil
 }
@@ -0,0 +1,83 @@
+package blackjack
+
+import (
+	"testing"
+
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+)
+
+func TestDecideFromChart(t *testing.T) {
+	tests := []struct {
+		name           string
+		actions        []Action
+		actionsAllowed map[Action]bool
+		want           Action
+		wantErr        bool
+	}{
+		{name: "first allowed", actions: []Action{Double, Hit}, actionsAllowed: allActions, want: Double},
+		{name: "double falls back to hit", actions: []Action{Double, Hit}, actionsAllowed: hitOrStand, want: Hit},
+		{name: "double falls back to stand", actions: []Action{Double, Stand}, actionsAllowed: hitOrStand, want: Stand},
+		{name: "split aces stand", actions: []Action{Hit}, actionsAllowed: map[Action]bool{Stand: true}, want: Stand},
+		{name: "none allowed", actions: []Action{Hit}, actionsAllowed: map[Action]bool{}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			action, err := decideFromChart(test.actions, DecisionContext{ActionsAllowed: test.actionsAllowed})
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got action %s", action)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action != test.want {
+				t.Errorf("got action %s, want %s", action, test.want)
+			}
+		})
+	}
+}
+
+func TestBasicStrategyDecide(t *testing.T) {
+	strategy, err := NewBasicStrategyS17()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noSplit := map[Action]bool{Hit: true, Stand: true, Double: true}
+
+	tests := []struct {
+		name           string
+		hand           testHand
+		upCard         core.Rank
+		actionsAllowed map[Action]bool
+		want           Action
+	}{
+		{name: "pair row first", hand: handOf(core.Eight, core.Eight), upCard: core.Ten, actionsAllowed: allActions, want: Split},
+		{name: "total row without split", hand: handOf(core.Eight, core.Eight), upCard: core.Ten, actionsAllowed: noSplit, want: Hit},
+		{name: "surrender", hand: handOf(core.Ten, core.Six), upCard: core.Ten, actionsAllowed: allActions, want: Surrender},
+		{name: "soft double", hand: handOf(core.Ace, core.Seven), upCard: core.Three, actionsAllowed: allActions, want: Double},
+		{name: "soft double falls back to stand", hand: handOf(core.Ace, core.Seven), upCard: core.Three, actionsAllowed: hitOrStand, want: Stand},
+		{name: "multi-card soft 18", hand: handOf(core.Ace, core.Two, core.Five), upCard: core.Nine, actionsAllowed: hitOrStand, want: Hit},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := DecisionContext{
+				PlayerHand:     test.hand,
+				DealerUpCard:   core.Card{Suit: core.Hearts, Rank: test.upCard},
+				ActionsAllowed: test.actionsAllowed,
+			}
+
+			action, err := strategy.Decide(decision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action != test.want {
+				t.Errorf("got action %s, want %s", action, test.want)
+			}
+		})
+	}
+}
@@ -31,6 +31,11 @@ func (c Card) Values() (int, int) {
 	}
 }
 
+// IsTenValue checks if the card is worth ten points.
+func (c Card) IsTenValue() bool {
+	return c.Rank >= Ten
+}
+
 func (c Card) String() string {
 	ranks := map[Rank]string{
 		Ace:   "A",
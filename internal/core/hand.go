@@ -18,4 +18,6 @@ type Hand interface {
 	IsBusted() bool
 	// IsPair checks if the hand is a pair (two cards of the same rank).
 	IsPair() bool
+	// Cards returns the cards in the hand in the order they were dealt.
+	Cards() []Card
 }
@@ -9,6 +9,7 @@ type Shoe struct {
 	cards       []Card
 	penetration float64
 	numDecks    uint
+	rankCounts  [King + 1]int
 }
 
 // NewShoe creates a shoe with a specified number of decks
@@ -18,6 +19,10 @@ func NewShoe(numDecks uint, penetration float64, rand *rand.Rand) *Shoe {
 		s.cards = append(s.cards, NewDeck()...)
 	}
 
+	for _, card := range s.cards {
+		s.rankCounts[card.Rank]++
+	}
+
 	rand.Shuffle(len(s.cards), func(i, j int) {
 		s.cards[i], s.cards[j] = s.cards[j], s.cards[i]
 	})
@@ -25,6 +30,19 @@ func NewShoe(numDecks uint, penetration float64, rand *rand.Rand) *Shoe {
 	return s
 }
 
+// NewShoeFromCards creates a shoe that deals the given cards in order, e.g. to
+// set up a known composition of the remaining cards.
+func NewShoeFromCards(cards []Card, numDecks uint, penetration float64) *Shoe {
+	s := &Shoe{numDecks: numDecks, penetration: penetration}
+	s.cards = append(s.cards, cards...)
+
+	for _, card := range s.cards {
+		s.rankCounts[card.Rank]++
+	}
+
+	return s
+}
+
 // Deal deals a card from the shoe
 func (s *Shoe) Deal() Card {
 	if len(s.cards) == 0 {
@@ -32,9 +50,37 @@ func (s *Shoe) Deal() Card {
 	}
 	card := s.cards[0]
 	s.cards = s.cards[1:]
+	s.rankCounts[card.Rank]--
 	return card
 }
 
+// Remaining returns the number of cards left in the shoe.
+func (s *Shoe) Remaining() int {
+	return len(s.cards)
+}
+
+// DecksRemaining returns the number of decks left in the shoe.
+func (s *Shoe) DecksRemaining() float64 {
+	return float64(len(s.cards)) / 52.0
+}
+
+// RemainingOfRank returns the number of cards of the given rank left in the
+// shoe.
+func (s *Shoe) RemainingOfRank(rank Rank) int {
+	return s.rankCounts[rank]
+}
+
+// RankCounts returns the number of cards of each rank left in the shoe,
+// indexed by rank.
+func (s *Shoe) RankCounts() [King + 1]int {
+	return s.rankCounts
+}
+
+// RemainingTenValue returns the number of ten-value cards left in the shoe.
+func (s *Shoe) RemainingTenValue() int {
+	return s.rankCounts[Ten] + s.rankCounts[Jack] + s.rankCounts[Queen] + s.rankCounts[King]
+}
+
 // NeedsShuffle checks if the shoe needs to be shuffled based on penetration
 func (s *Shoe) NeedsShuffle() bool {
 	return float64(len(s.cards)) < float64(s.numDecks*52)*(1.0-s.penetration)
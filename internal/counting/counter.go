@@ -0,0 +1,57 @@
+package counting
+
+import "github.com/jljl1337/blackjack-simulator/internal/core"
+
+// Counter keeps the running count of the cards seen from a shoe using a
+// counting system.
+type Counter struct {
+	system       System
+	numDecks     uint
+	runningCount float64
+	acesSeen     int
+}
+
+// NewCounter creates a new Counter for a shoe of numDecks decks, starting at
+// the system's initial running count.
+func NewCounter(system System, numDecks uint) *Counter {
+	return &Counter{
+		system:       system,
+		numDecks:     numDecks,
+		runningCount: system.InitialRunningCount(numDecks),
+	}
+}
+
+// Observe updates the running count with a card that has been exposed.
+func (c *Counter) Observe(card core.Card) {
+	c.runningCount += c.system.Tags.Tag(card.Rank)
+	if card.Rank == core.Ace {
+		c.acesSeen++
+	}
+}
+
+// RunningCount returns the running count.
+func (c Counter) RunningCount() float64 {
+	return c.runningCount
+}
+
+// TrueCount returns the running count per deck remaining in the shoe. For an
+// unbalanced system there is no true count conversion and the running count
+// is returned as is.
+func (c Counter) TrueCount(decksRemaining float64) float64 {
+	if !c.system.IsBalanced() || decksRemaining <= 0 {
+		return c.runningCount
+	}
+	return c.runningCount / decksRemaining
+}
+
+// HasAceSideCount reports whether the counter keeps an ace side count.
+func (c Counter) HasAceSideCount() bool {
+	return c.system.AceSideCount
+}
+
+// ExcessAces returns the number of aces remaining in the shoe above the
+// average for the decks remaining, based on the ace side count.
+func (c Counter) ExcessAces(decksRemaining float64) float64 {
+	acesRemaining := float64(4*int(c.numDecks) - c.acesSeen)
+	return acesRemaining - 4*decksRemaining
+}
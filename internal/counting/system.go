@@ -0,0 +1,193 @@
+package counting
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+)
+
+// Tags holds the count value of each rank, indexed by core.Rank.
+type Tags [core.King + 1]float64
+
+// Tag returns the count value of the given rank.
+func (t Tags) Tag(rank core.Rank) float64 {
+	return t[rank]
+}
+
+// deckSum returns the sum of the tags over a full 52-card deck.
+func (t Tags) deckSum() float64 {
+	sum := 0.0
+	for rank := core.Ace; rank <= core.King; rank++ {
+		sum += 4 * t[rank]
+	}
+	return sum
+}
+
+// System is a card counting system defined by a tag table.
+type System struct {
+	Name string
+	Tags Tags
+	// InitialRunningCountPerDeck and InitialRunningCountOffset give the
+	// initial running count of an unbalanced system as
+	// offset + perDeck * numDecks, e.g. 4 - 4 * numDecks for KO.
+	InitialRunningCountPerDeck float64
+	InitialRunningCountOffset  float64
+	// AceSideCount keeps a separate count of the aces seen, for systems that
+	// count aces as neutral.
+	AceSideCount bool
+}
+
+// IsBalanced reports whether the tags sum to zero over a full deck. Balanced
+// systems convert the running count to a true count, unbalanced systems use
+// the running count directly.
+func (s System) IsBalanced() bool {
+	return s.deckSum() == 0
+}
+
+func (s System) deckSum() float64 {
+	return s.Tags.deckSum()
+}
+
+// InitialRunningCount returns the running count at the start of a shoe.
+func (s System) InitialRunningCount(numDecks uint) float64 {
+	if s.IsBalanced() {
+		return 0
+	}
+	return s.InitialRunningCountOffset + s.InitialRunningCountPerDeck*float64(numDecks)
+}
+
+// newTags creates a tag table from the tags of A, 2, ..., 9 and the
+// ten-value cards.
+func newTags(ace, two, three, four, five, six, seven, eight, nine, ten float64) Tags {
+	return Tags{
+		core.Ace:   ace,
+		core.Two:   two,
+		core.Three: three,
+		core.Four:  four,
+		core.Five:  five,
+		core.Six:   six,
+		core.Seven: seven,
+		core.Eight: eight,
+		core.Nine:  nine,
+		core.Ten:   ten,
+		core.Jack:  ten,
+		core.Queen: ten,
+		core.King:  ten,
+	}
+}
+
+var systems = map[string]System{
+	"hiLo": {
+		Name: "hiLo",
+		Tags: newTags(-1, 1, 1, 1, 1, 1, 0, 0, 0, -1),
+	},
+	"ko": {
+		Name:                       "ko",
+		Tags:                       newTags(-1, 1, 1, 1, 1, 1, 1, 0, 0, -1),
+		InitialRunningCountPerDeck: -4,
+		InitialRunningCountOffset:  4,
+	},
+	"hiOptI": {
+		Name:         "hiOptI",
+		Tags:         newTags(0, 0, 1, 1, 1, 1, 0, 0, 0, -1),
+		AceSideCount: true,
+	},
+	"hiOptII": {
+		Name:         "hiOptII",
+		Tags:         newTags(0, 1, 1, 2, 2, 1, 1, 0, 0, -2),
+		AceSideCount: true,
+	},
+	"omegaII": {
+		Name:         "omegaII",
+		Tags:         newTags(0, 1, 1, 2, 2, 2, 1, 0, -1, -2),
+		AceSideCount: true,
+	},
+	"zen": {
+		Name: "zen",
+		Tags: newTags(-1, 1, 1, 2, 2, 2, 1, 0, 0, -2),
+	},
+	"wongHalves": {
+		Name: "wongHalves",
+		Tags: newTags(-1, 0.5, 1, 1, 1.5, 1, 0.5, 0, -0.5, -1),
+	},
+}
+
+// GetSystem returns a built-in counting system by name, one of "hiLo", "ko",
+// "hiOptI", "hiOptII", "omegaII", "zen" or "wongHalves".
+func GetSystem(name string) (System, error) {
+	system, exists := systems[name]
+	if !exists {
+		return System{}, fmt.Errorf("unknown counting system: %s", name)
+	}
+	return system, nil
+}
+
+// systemFile is the JSON representation of a counting system.
+type systemFile struct {
+	Name                       string             `json:"name"`
+	Tags                       map[string]float64 `json:"tags"`
+	InitialRunningCountPerDeck float64            `json:"initialRunningCountPerDeck"`
+	InitialRunningCountOffset  float64            `json:"initialRunningCountOffset"`
+	AceSideCount               bool               `json:"aceSideCount"`
+}
+
+// LoadSystemFromFile loads a counting system from a JSON file. Tags are keyed
+// by "A", "2" to "10", "J", "Q" and "K", where "10" also applies to any of
+// "J", "Q" and "K" that are not specified.
+func LoadSystemFromFile(filePath string) (System, error) {
+	bytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return System{}, err
+	}
+
+	var file systemFile
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return System{}, err
+	}
+
+	ranks := map[string]core.Rank{
+		"A":  core.Ace,
+		"2":  core.Two,
+		"3":  core.Three,
+		"4":  core.Four,
+		"5":  core.Five,
+		"6":  core.Six,
+		"7":  core.Seven,
+		"8":  core.Eight,
+		"9":  core.Nine,
+		"10": core.Ten,
+		"J":  core.Jack,
+		"Q":  core.Queen,
+		"K":  core.King,
+	}
+
+	var tags Tags
+	for key, tag := range file.Tags {
+		rank, exists := ranks[key]
+		if !exists {
+			return System{}, fmt.Errorf("invalid rank in tags: %s", key)
+		}
+		tags[rank] = tag
+	}
+
+	for _, key := range []string{"J", "Q", "K"} {
+		if _, exists := file.Tags[key]; !exists {
+			tags[ranks[key]] = tags[core.Ten]
+		}
+	}
+
+	name := file.Name
+	if name == "" {
+		name = filePath
+	}
+
+	return System{
+		Name:                       name,
+		Tags:                       tags,
+		InitialRunningCountPerDeck: file.InitialRunningCountPerDeck,
+		InitialRunningCountOffset:  file.InitialRunningCountOffset,
+		AceSideCount:               file.AceSideCount,
+	}, nil
+}
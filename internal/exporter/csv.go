@@ -4,17 +4,20 @@ import (
 	"encoding/csv"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/jljl1337/blackjack-simulator/internal/result"
 )
 
 type CSVExporter struct {
-	filePath string
+	filePath   string
+	dealerRule string
 }
 
-func NewCSVExporter(filePath string) *CSVExporter {
+func NewCSVExporter(filePath string, dealerRule string) *CSVExporter {
 	return &CSVExporter{
-		filePath: filePath,
+		filePath:   filePath,
+		dealerRule: dealerRule,
 	}
 }
 
@@ -35,6 +38,17 @@ func (e CSVExporter) Export(results []result.ShuffleResult) error {
 		"player_actions",
 		"bet_placed",
 		"bet",
+		"insurance_placed",
+		"insurance",
+		"even_money",
+		"charlie",
+		"pushed_22",
+		"running_count",
+		"true_count",
+		"excess_aces",
+		"observed",
+		"deviations",
+		"dealer_rule",
 	})
 
 	id := 0
@@ -42,6 +56,12 @@ func (e CSVExporter) Export(results []result.ShuffleResult) error {
 	for resultID, result := range results {
 		for roundID, roundResult := range result.RoundResults {
 			for handID, playerHand := range roundResult.PlayerHands {
+				runningCount := strconv.FormatFloat(roundResult.RunningCount, 'f', -1, 64)
+				trueCount := strconv.FormatFloat(roundResult.TrueCount, 'f', 2, 64)
+				excessAces := ""
+				if roundResult.ExcessAces != nil {
+					excessAces = strconv.FormatFloat(*roundResult.ExcessAces, 'f', 2, 64)
+				}
 				dealerHand := roundResult.DealerHand.String()
 				playerHands := playerHand.Hand.String()
 				dealerHandValue := strconv.Itoa(roundResult.DealerHand.Value())
@@ -51,6 +71,12 @@ func (e CSVExporter) Export(results []result.ShuffleResult) error {
 				playerActions := playerHand.GetActions()
 				betPlaced := strconv.Itoa(playerHand.GetBetPlaced())
 				bet := strconv.Itoa(playerHand.GetBet())
+				insurancePlaced := strconv.Itoa(playerHand.GetInsuranceBetPlaced())
+				insurance := strconv.Itoa(playerHand.GetInsuranceBet())
+				evenMoney := strconv.FormatBool(playerHand.TookEvenMoney())
+				charlie := strconv.FormatBool(playerHand.IsCharlie())
+				pushed22 := strconv.FormatBool(playerHand.IsPushed22())
+				deviations := strings.Join(playerHand.GetDeviations(), ";")
 
 				// Convert player actions to a string representation
 				playerActionsString := ""
@@ -77,6 +103,17 @@ func (e CSVExporter) Export(results []result.ShuffleResult) error {
 					playerActionsString,
 					betPlaced,
 					bet,
+					insurancePlaced,
+					insurance,
+					evenMoney,
+					charlie,
+					pushed22,
+					runningCount,
+					trueCount,
+					excessAces,
+					strconv.FormatBool(roundResult.Observed),
+					deviations,
+					e.dealerRule,
 				})
 
 				id++
@@ -84,16 +121,16 @@ func (e CSVExporter) Export(results []result.ShuffleResult) error {
 		}
 	}
 
-	if err := e.saveToCSV(data); err != nil {
+	if err := saveToCSV(e.filePath, data); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (e CSVExporter) saveToCSV(data [][]string) error {
+func saveToCSV(filePath string, data [][]string) error {
 	// Create or truncate output CSV file
-	file, err := os.Create(e.filePath)
+	file, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,47 @@
+package exporter
+
+import (
+	"strconv"
+
+	"github.com/jljl1337/blackjack-simulator/internal/result"
+)
+
+// TrueCountCSVExporter exports the statistics of the rounds by true count.
+type TrueCountCSVExporter struct {
+	filePath string
+}
+
+func NewTrueCountCSVExporter(filePath string) *TrueCountCSVExporter {
+	return &TrueCountCSVExporter{
+		filePath: filePath,
+	}
+}
+
+func (e TrueCountCSVExporter) Export(buckets []result.TrueCountBucket) error {
+	numRounds := 0
+	for _, bucket := range buckets {
+		numRounds += bucket.NumRounds
+	}
+
+	data := make([][]string, 0, len(buckets)+1)
+
+	data = append(data, []string{
+		"true_count",
+		"num_rounds",
+		"frequency",
+		"average_result",
+		"variance",
+	})
+
+	for _, bucket := range buckets {
+		data = append(data, []string{
+			strconv.Itoa(bucket.TrueCount),
+			strconv.Itoa(bucket.NumRounds),
+			strconv.FormatFloat(float64(bucket.NumRounds)/float64(numRounds), 'f', 6, 64),
+			strconv.FormatFloat(bucket.AverageResult(), 'f', 6, 64),
+			strconv.FormatFloat(bucket.Variance(), 'f', 6, 64),
+		})
+	}
+
+	return saveToCSV(e.filePath, data)
+}
@@ -20,15 +20,24 @@ func (d Dealer) HasBlackjack() bool {
 	return d.hand.IsBlackjack()
 }
 
-func (d Dealer) NeedsToHit() bool {
-	// Dealer hits on 16 or less, stands on 17 or more
-	return d.hand.Value() < 17
+// NeedsToHit reports whether the dealer must draw another card. The dealer
+// always hits on 16 or less, and also hits on soft 17 if hitSoft17 is set.
+func (d Dealer) NeedsToHit(hitSoft17 bool) bool {
+	value := d.hand.Value()
+	if value == 17 && hitSoft17 {
+		return d.hand.IsSoft()
+	}
+	return value < 17
 }
 
 func (d Dealer) GetUpCard() core.Card {
 	return d.hand.cards[0]
 }
 
+func (d Dealer) GetHoleCard() core.Card {
+	return d.hand.cards[1]
+}
+
 func (d Dealer) GetHandValue() int {
 	return d.hand.Value()
 }
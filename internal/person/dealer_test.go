@@ -0,0 +1,45 @@
+package person
+
+import (
+	"testing"
+
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+)
+
+func dealerWith(ranks ...core.Rank) *Dealer {
+	dealer := NewDealer()
+	for _, rank := range ranks {
+		dealer.DrawCard(core.Card{Suit: core.Spades, Rank: rank})
+	}
+	return dealer
+}
+
+func TestDealerNeedsToHit(t *testing.T) {
+	tests := []struct {
+		name      string
+		ranks     []core.Rank
+		hitSoft17 bool
+		want      bool
+	}{
+		{name: "A-6 H17", ranks: []core.Rank{core.Ace, core.Six}, hitSoft17: true, want: true},
+		{name: "A-6 S17", ranks: []core.Rank{core.Ace, core.Six}, hitSoft17: false, want: false},
+		{name: "A-A-5 H17", ranks: []core.Rank{core.Ace, core.Ace, core.Five}, hitSoft17: true, want: true},
+		{name: "A-A-5 S17", ranks: []core.Rank{core.Ace, core.Ace, core.Five}, hitSoft17: false, want: false},
+		{name: "A-A-A-4 H17", ranks: []core.Rank{core.Ace, core.Ace, core.Ace, core.Four}, hitSoft17: true, want: true},
+		{name: "A-A-A-4 S17", ranks: []core.Rank{core.Ace, core.Ace, core.Ace, core.Four}, hitSoft17: false, want: false},
+		{name: "A-A-2-3 H17", ranks: []core.Rank{core.Ace, core.Ace, core.Two, core.Three}, hitSoft17: true, want: true},
+		{name: "A-A-2-3 S17", ranks: []core.Rank{core.Ace, core.Ace, core.Two, core.Three}, hitSoft17: false, want: false},
+		{name: "10-6-A H17", ranks: []core.Rank{core.Ten, core.Six, core.Ace}, hitSoft17: true, want: false},
+		{name: "10-7 H17", ranks: []core.Rank{core.Ten, core.Seven}, hitSoft17: true, want: false},
+		{name: "10-6 S17", ranks: []core.Rank{core.Ten, core.Six}, hitSoft17: false, want: true},
+		{name: "A-A-6 H17", ranks: []core.Rank{core.Ace, core.Ace, core.Six}, hitSoft17: true, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := dealerWith(test.ranks...).NeedsToHit(test.hitSoft17); got != test.want {
+				t.Errorf("NeedsToHit(%t) = %t, want %t", test.hitSoft17, got, test.want)
+			}
+		})
+	}
+}
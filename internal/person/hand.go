@@ -8,6 +8,9 @@ import (
 
 type Hand struct {
 	cards []core.Card
+	// splitUnlikeTens makes any two ten-value cards a pair, e.g. K-Q,
+	// instead of requiring two cards of the same rank.
+	splitUnlikeTens bool
 }
 
 func (h Hand) ValueString() string {
@@ -50,14 +53,18 @@ func (h Hand) IsBlackjack() bool {
 	return len(h.cards) == 2 && h.Value() == 21 && h.IsSoft()
 }
 
+// IsSoft checks if the hand holds an ace that can count as 11 without
+// busting, i.e. its hard total plus 10 is at most 21. Only one ace can ever
+// count as 11, so A-A-5 is a soft 17.
 func (h Hand) IsSoft() bool {
-	lowVal, highVal := 0, 0
+	hardValue := 0
+	hasAce := false
 	for _, card := range h.cards {
-		l, h := card.Values()
-		lowVal += l
-		highVal += h
+		lowValue, _ := card.Values()
+		hardValue += lowValue
+		hasAce = hasAce || card.Rank == core.Ace
 	}
-	return highVal != lowVal && highVal <= 21
+	return hasAce && hardValue+10 <= 21
 }
 
 func (h Hand) IsBusted() bool {
@@ -65,7 +72,35 @@ func (h Hand) IsBusted() bool {
 }
 
 func (h Hand) IsPair() bool {
-	return len(h.cards) == 2 && h.cards[0].Rank == h.cards[1].Rank
+	if len(h.cards) != 2 {
+		return false
+	}
+
+	if h.splitUnlikeTens && h.cards[0].IsTenValue() && h.cards[1].IsTenValue() {
+		return true
+	}
+
+	return h.cards[0].Rank == h.cards[1].Rank
+}
+
+// IsSuited checks if the hand has at least two cards and all of them share
+// the same suit.
+func (h Hand) IsSuited() bool {
+	if len(h.cards) < 2 {
+		return false
+	}
+
+	for _, card := range h.cards[1:] {
+		if card.Suit != h.cards[0].Suit {
+			return false
+		}
+	}
+	return true
+}
+
+// Cards returns the cards in the hand in the order they were dealt.
+func (h Hand) Cards() []core.Card {
+	return h.cards
 }
 
 func (h Hand) GetSize() int {
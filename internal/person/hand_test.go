@@ -0,0 +1,55 @@
+package person
+
+import (
+	"testing"
+
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+)
+
+func handWith(ranks ...core.Rank) Hand {
+	hand := Hand{}
+	for _, rank := range ranks {
+		hand.AddCard(core.Card{Suit: core.Spades, Rank: rank})
+	}
+	return hand
+}
+
+func TestHandValueString(t *testing.T) {
+	tests := []struct {
+		ranks []core.Rank
+		want  string
+	}{
+		{ranks: []core.Rank{core.Ace, core.Ace}, want: "S12"},
+		{ranks: []core.Rank{core.Ace, core.Ace, core.Five}, want: "S17"},
+		{ranks: []core.Rank{core.Ace, core.Ace, core.Ace, core.Four}, want: "S17"},
+		{ranks: []core.Rank{core.Ace, core.King}, want: "S21"},
+		{ranks: []core.Rank{core.Ace, core.Six, core.Ten}, want: "H17"},
+		{ranks: []core.Rank{core.Ace, core.Ace, core.Ten}, want: "H12"},
+		{ranks: []core.Rank{core.Nine, core.Seven}, want: "H16"},
+	}
+
+	for _, test := range tests {
+		hand := handWith(test.ranks...)
+		if got := hand.ValueString(); got != test.want {
+			t.Errorf("%s: ValueString() = %s, want %s", hand, got, test.want)
+		}
+	}
+}
+
+func TestHandIsSuited(t *testing.T) {
+	if (Hand{}).IsSuited() {
+		t.Error("empty hand is suited")
+	}
+	if handWith(core.Ace).IsSuited() {
+		t.Error("one-card hand is suited")
+	}
+	if !handWith(core.Ace, core.King).IsSuited() {
+		t.Error("A-K of spades is not suited")
+	}
+
+	hand := handWith(core.Ace)
+	hand.AddCard(core.Card{Suit: core.Hearts, Rank: core.King})
+	if hand.IsSuited() {
+		t.Error("A of spades and K of hearts is suited")
+	}
+}
@@ -3,55 +3,174 @@ package person
 import (
 	"errors"
 
+	"github.com/jljl1337/blackjack-simulator/internal/betting"
 	"github.com/jljl1337/blackjack-simulator/internal/blackjack"
 	"github.com/jljl1337/blackjack-simulator/internal/core"
 )
 
 type Player struct {
-	currentHand int
-	hands       []*PlayerHand
-	strategy    blackjack.Strategy
+	currentHand     int
+	initialBet      int
+	hands           []*PlayerHand
+	strategy        blackjack.Strategy
+	insurancePolicy blackjack.InsurancePolicy
+	bettingPolicy   betting.Policy
+	indexTable      *blackjack.IndexTable
+	wong            *betting.Wong
+	seated          bool
+	splitUnlikeTens bool
 }
 
-func NewPlayer(strategy blackjack.Strategy) *Player {
+// NewPlayer creates a new Player. The index table is optional and may be nil
+// to play basic strategy only. The wong is optional and may be nil to bet
+// every round.
+func NewPlayer(
+	strategy blackjack.Strategy,
+	insurancePolicy blackjack.InsurancePolicy,
+	bettingPolicy betting.Policy,
+	indexTable *blackjack.IndexTable,
+	wong *betting.Wong,
+	splitUnlikeTens bool,
+) *Player {
 	return &Player{
-		hands:    []*PlayerHand{NewPlayerHand()},
-		strategy: strategy,
+		hands:           []*PlayerHand{NewPlayerHand(splitUnlikeTens)},
+		strategy:        strategy,
+		insurancePolicy: insurancePolicy,
+		bettingPolicy:   bettingPolicy,
+		indexTable:      indexTable,
+		wong:            wong,
+		seated:          wong == nil,
+		splitUnlikeTens: splitUnlikeTens,
 	}
 }
 
-func (p *Player) PlaceBet() error {
-	const betAmount = 100 // TODO: card counting
+// PlaceBet places the initial bet of the round, sized by the betting policy
+// for the true count at the start of the round. A back-counter who is not
+// seated bets nothing and only watches the round.
+func (p *Player) PlaceBet(trueCount float64, firstRound bool) error {
 	currentHand, err := p.getCurrentHand()
 	if err != nil {
 		return err
 	}
 
+	if p.wong != nil {
+		p.seated = p.wong.Plays(p.seated, firstRound, trueCount)
+	}
+
+	betAmount := 0
+	if p.seated {
+		betAmount = p.bettingPolicy.Bet(trueCount)
+	}
+
 	currentHand.PlaceBet(betAmount)
+	p.initialBet = betAmount
 
 	return nil
 }
 
+// OfferInsurance asks the insurance policy whether to take insurance when the
+// dealer shows an ace, unless the index table has an insurance index, which
+// decides by the true count instead. If the current hand is a natural,
+// taking insurance means taking even money, which settles the hand at 1:1
+// immediately. Otherwise an insurance wager of half the bet is placed.
+func (p *Player) OfferInsurance(unseenCards, unseenTenValueCards int, trueCount float64) error {
+	currentHand, err := p.getCurrentHand()
+	if err != nil {
+		return err
+	}
+
+	if !p.seated {
+		return nil
+	}
+
+	if p.indexTable != nil && p.indexTable.HasInsurance() {
+		index, applies := p.indexTable.LookupInsurance(trueCount)
+		if !applies {
+			return nil
+		}
+		currentHand.AddDeviation(index.Name())
+	} else if !p.insurancePolicy.TakeInsurance(currentHand, unseenCards, unseenTenValueCards, trueCount) {
+		return nil
+	}
+
+	if currentHand.IsBlackjack() {
+		currentHand.evenMoney = true
+		currentHand.WinByPayout(blackjack.EvenMoney)
+		return nil
+	}
+
+	currentHand.PlaceInsurance(currentHand.GetBet() / 2)
+	return nil
+}
+
+// SettleInsurance settles the insurance wager, if any, once the dealer has
+// checked for blackjack.
+func (p *Player) SettleInsurance(dealerHasBlackjack bool) error {
+	// Insurance is always placed on the first hand, before any split
+	firstHand, err := p.getHand(0)
+	if err != nil {
+		return err
+	}
+
+	if firstHand.GetInsuranceBetPlaced() > 0 {
+		firstHand.SettleInsurance(dealerHasBlackjack)
+	}
+	return nil
+}
+
 // CalculateHandBet calculates the final value of each bet at the end of the
 // round, based on the dealer's hand value and the player's hand value.
-func (p *Player) CalculateHandBet(dealerValue int) {
+func (p *Player) CalculateHandBet(dealerValue int, rules SettlementRules) {
+	dealerPushes := rules.DealerPush22 && dealerValue == 22
+
 	for i, hand := range p.hands {
 		if hand.GetBet() != hand.GetBetPlaced() {
-			// This hand is either busted or surrendered
-		} else if hand.IsBlackjack() {
+			// This hand is already settled, e.g. busted or surrendered
+		} else if rules.isBlackjack(hand) && (!dealerPushes || rules.NaturalBeatsDealer22) {
 			// Note that if the dealer has a blackjack, the bet is calculated at
 			// the beginning of the round, so we don't need to check for that.
-			p.winHand(i, 1.5)
+			p.winHand(i, rules.blackjackPayout(hand.Hand))
+		} else if dealerPushes {
+			// The dealer's 22 pushes every hand still in play
+			hand.pushed22 = true
 		} else if dealerValue > 21 || hand.Value() > dealerValue {
-			p.winHand(i, 1.0)
+			p.winHand(i, blackjack.EvenMoney)
 		} else if hand.Value() < dealerValue {
-			p.loseHand(i, 1.0)
+			p.loseHand(i, blackjack.EvenMoney)
 		}
 
 		// If the values are equal, the bet remains the same (push)
 	}
 }
 
+// LoseToDealerBlackjack settles the player's hands when the dealer turns
+// over a blackjack after the player has acted, as happens when there is no
+// hole card. Naturals push, and hands surrendered early and Charlie hands are
+// already settled. All other wagers are lost, including hands surrendered
+// late, as late surrender is only offered once the dealer does not have
+// blackjack, unless the rules limit the loss to the original bet.
+func (p *Player) LoseToDealerBlackjack(rules SettlementRules) {
+	originalBetLost := false
+	for _, hand := range p.hands {
+		if rules.isBlackjack(hand) || hand.IsSurrenderedEarly() || hand.IsCharlie() {
+			continue
+		}
+
+		if !rules.OriginalBetsOnly {
+			hand.bet = 0
+			continue
+		}
+
+		// Return doubles and splits, including busted ones, and only take
+		// the original bet once
+		hand.bet = hand.betPlaced
+		if !originalBetLost {
+			hand.bet -= p.initialBet
+			originalBetLost = true
+		}
+	}
+}
+
 func (p *Player) DrawCard(card core.Card) error {
 	currentHand, err := p.getCurrentHand()
 	if err != nil {
@@ -75,6 +194,17 @@ func (p Player) CurrentHandIsBlackjack() (bool, error) {
 	return currentHand.IsBlackjack(), nil
 }
 
+// CurrentHandIsNatural checks if the current hand is a blackjack dealt on the
+// first two cards, not formed by splitting.
+func (p Player) CurrentHandIsNatural() (bool, error) {
+	currentHand, err := p.getCurrentHand()
+	if err != nil {
+		return false, err
+	}
+
+	return currentHand.IsNatural(), nil
+}
+
 func (p Player) CurrentHandIsBusted() (bool, error) {
 	currentHand, err := p.getCurrentHand()
 	if err != nil {
@@ -84,6 +214,15 @@ func (p Player) CurrentHandIsBusted() (bool, error) {
 	return currentHand.IsBusted(), nil
 }
 
+func (p Player) GetCurrentHand() (Hand, error) {
+	currentHand, err := p.getCurrentHand()
+	if err != nil {
+		return Hand{}, err
+	}
+
+	return currentHand.Hand, nil
+}
+
 func (p Player) GetCurrentHandSize() (int, error) {
 	currentHand, err := p.getCurrentHand()
 	if err != nil {
@@ -93,19 +232,48 @@ func (p Player) GetCurrentHandSize() (int, error) {
 	return len(currentHand.cards), nil
 }
 
-func (p *Player) GetActions(dealerUpCard core.Card) ([]blackjack.Action, error) {
+// Decide asks the strategy for the action on the current hand, adding what
+// the player knows about its hands to the decision.
+func (p *Player) Decide(decision blackjack.DecisionContext) (blackjack.Action, error) {
 	currentHand, err := p.getCurrentHand()
 	if err != nil {
-		return nil, err
+		return blackjack.NA, err
 	}
 
-	// Use the strategy to get the actions for the current hand
-	actions, err := p.strategy.GetActions(currentHand, dealerUpCard)
+	decision.PlayerHand = currentHand
+	decision.NumHands = len(p.hands)
+	decision.FromSplit = currentHand.IsFromSplit()
+	decision.SplitAce = p.SplitAce()
+
+	return p.strategy.Decide(decision)
+}
+
+// GetIndexes returns the indexes in the index table that apply to the current
+// hand at the true count, or nil if the player has no index table. splitting
+// reports whether the strategy splits the hand, when only the pair rows apply.
+func (p *Player) GetIndexes(dealerUpCard core.Card, trueCount float64, splitting bool) ([]blackjack.Index, error) {
+	currentHand, err := p.getCurrentHand()
 	if err != nil {
 		return nil, err
 	}
 
-	return actions, nil
+	if p.indexTable == nil {
+		return nil, nil
+	}
+
+	return p.indexTable.Lookup(currentHand, dealerUpCard, trueCount, splitting), nil
+}
+
+// RecordDeviation records that an index changed the decision on the current
+// hand.
+func (p *Player) RecordDeviation(index blackjack.Index) error {
+	currentHand, err := p.getCurrentHand()
+	if err != nil {
+		return err
+	}
+
+	currentHand.AddDeviation(index.Name())
+	return nil
 }
 
 func (p *Player) RecordAction(action blackjack.Action) error {
@@ -165,9 +333,12 @@ func (p *Player) Split(newCards []core.Card) error {
 	}
 
 	// Create a new hand
-	newHand := NewPlayerHand()
+	newHand := NewPlayerHand(p.splitUnlikeTens)
 	p.hands = append(p.hands, newHand)
 
+	currentHand.fromSplit = true
+	newHand.fromSplit = true
+
 	// Adjust the bet for the new hand
 	newHand.PlaceBet(currentHand.GetBet())
 
@@ -182,29 +353,59 @@ func (p *Player) Split(newCards []core.Card) error {
 	return nil
 }
 
-func (p *Player) WinCurrentHand(winRatio float64) error {
-	return p.winHand(p.currentHand, winRatio)
+func (p *Player) WinCurrentHand(payout blackjack.Payout) error {
+	return p.winHand(p.currentHand, payout)
 }
 
-func (p *Player) LoseCurrentHand(loseRatio float64) error {
-	return p.loseHand(p.currentHand, loseRatio)
+// WinCurrentHandByCharlie settles the current hand as an automatic win for
+// reaching the Charlie number of cards without busting.
+func (p *Player) WinCurrentHandByCharlie(payout blackjack.Payout) error {
+	currentHand, err := p.getCurrentHand()
+	if err != nil {
+		return err
+	}
+
+	currentHand.charlie = true
+	currentHand.WinByPayout(payout)
+	return nil
 }
 
-func (p *Player) winHand(index int, winRatio float64) error {
-	return p.adjustHandBet(index, 1.0+winRatio)
+func (p *Player) LoseCurrentHand(payout blackjack.Payout) error {
+	return p.loseHand(p.currentHand, payout)
+}
+
+// SurrenderCurrentHandEarly settles the current hand as surrendered before
+// the dealer checks for blackjack, losing half the bet whatever the dealer
+// has.
+func (p *Player) SurrenderCurrentHandEarly() error {
+	currentHand, err := p.getCurrentHand()
+	if err != nil {
+		return err
+	}
+
+	currentHand.surrenderedEarly = true
+	currentHand.LoseByPayout(blackjack.HalfBet)
+	return nil
 }
 
-func (p *Player) loseHand(index int, loseRatio float64) error {
-	return p.adjustHandBet(index, 1.0-loseRatio)
+func (p *Player) winHand(index int, payout blackjack.Payout) error {
+	hand, err := p.getHand(index)
+	if err != nil {
+		return err
+	}
+
+	hand.WinByPayout(payout)
+
+	return nil
 }
 
-func (p *Player) adjustHandBet(index int, ratio float64) error {
+func (p *Player) loseHand(index int, payout blackjack.Payout) error {
 	hand, err := p.getHand(index)
 	if err != nil {
 		return err
 	}
 
-	hand.AdjustBetByRatio(ratio)
+	hand.LoseByPayout(payout)
 
 	return nil
 }
@@ -242,6 +443,18 @@ func (p Player) SplitAce() bool {
 	return len(p.hands) > 1 && p.hands[0].cards[0].Rank == core.Ace
 }
 
+// IsSeated reports whether the player bets on the current round, rather than
+// watching it.
+func (p Player) IsSeated() bool {
+	return p.seated
+}
+
+// GetInitialBet returns the initial bet of the round, before any doubles or
+// splits.
+func (p Player) GetInitialBet() int {
+	return p.initialBet
+}
+
 func (p Player) GetNumHands() int {
 	return len(p.hands)
 }
@@ -253,5 +466,6 @@ func (p Player) GetHands() []*PlayerHand {
 // EndRound resets the player's state for a new round
 func (p *Player) EndRound() {
 	p.currentHand = 0
-	p.hands = []*PlayerHand{NewPlayerHand()}
+	p.initialBet = 0
+	p.hands = []*PlayerHand{NewPlayerHand(p.splitUnlikeTens)}
 }
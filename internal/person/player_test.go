@@ -0,0 +1,204 @@
+package person
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jljl1337/blackjack-simulator/internal/blackjack"
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+)
+
+// playerHandWith returns a hand of the cards with the bet placed on it.
+func playerHandWith(bet int, ranks ...core.Rank) *PlayerHand {
+	hand := NewPlayerHand(false)
+	hand.Hand = handWith(ranks...)
+	hand.PlaceBet(bet)
+	return hand
+}
+
+// splitHandWith returns a hand formed by splitting with the bet placed on it.
+func splitHandWith(bet int, ranks ...core.Rank) *PlayerHand {
+	hand := playerHandWith(bet, ranks...)
+	hand.fromSplit = true
+	return hand
+}
+
+// lost returns the hand after losing the payout of its bet, as when it busts
+// or surrenders.
+func lost(hand *PlayerHand, payout blackjack.Payout) *PlayerHand {
+	hand.LoseByPayout(payout)
+	return hand
+}
+
+// surrenderedEarly returns the hand after surrendering it before the dealer
+// checks for blackjack.
+func surrenderedEarly(hand *PlayerHand) *PlayerHand {
+	hand.surrenderedEarly = true
+	return lost(hand, blackjack.HalfBet)
+}
+
+// wonByCharlie returns the hand after winning it by reaching the Charlie
+// number of cards.
+func wonByCharlie(hand *PlayerHand) *PlayerHand {
+	hand.charlie = true
+	hand.WinByPayout(blackjack.EvenMoney)
+	return hand
+}
+
+// bets returns the final bet of each of the player's hands.
+func bets(player *Player) []int {
+	bets := make([]int, 0, len(player.hands))
+	for _, hand := range player.hands {
+		bets = append(bets, hand.GetBet())
+	}
+	return bets
+}
+
+func TestLoseToDealerBlackjack(t *testing.T) {
+	enhc := SettlementRules{}
+	obo := SettlementRules{OriginalBetsOnly: true}
+
+	tests := []struct {
+		name  string
+		rules SettlementRules
+		hands []*PlayerHand
+		want  []int
+	}{
+		{
+			name:  "enhc doubled",
+			rules: enhc,
+			hands: []*PlayerHand{playerHandWith(20, core.Five, core.Six, core.Nine)},
+			want:  []int{0},
+		},
+		{
+			name:  "obo doubled",
+			rules: obo,
+			hands: []*PlayerHand{playerHandWith(20, core.Five, core.Six, core.Nine)},
+			want:  []int{10},
+		},
+		{
+			name:  "enhc split",
+			rules: enhc,
+			hands: []*PlayerHand{splitHandWith(10, core.Eight, core.Ten), splitHandWith(20, core.Eight, core.Three, core.Nine)},
+			want:  []int{0, 0},
+		},
+		{
+			name:  "obo split",
+			rules: obo,
+			hands: []*PlayerHand{splitHandWith(10, core.Eight, core.Ten), splitHandWith(20, core.Eight, core.Three, core.Nine)},
+			want:  []int{0, 20},
+		},
+		{
+			// The busted hand is returned and the original bet is taken from
+			// the first hand
+			name:  "obo split busted",
+			rules: obo,
+			hands: []*PlayerHand{lost(splitHandWith(10, core.Eight, core.Six, core.Ten), blackjack.EvenMoney), splitHandWith(10, core.Eight, core.Ten)},
+			want:  []int{0, 10},
+		},
+		{
+			name:  "enhc surrendered early",
+			rules: enhc,
+			hands: []*PlayerHand{surrenderedEarly(playerHandWith(10, core.Ten, core.Six))},
+			want:  []int{5},
+		},
+		{
+			name:  "obo surrendered early",
+			rules: obo,
+			hands: []*PlayerHand{surrenderedEarly(playerHandWith(10, core.Ten, core.Six))},
+			want:  []int{5},
+		},
+		{
+			name:  "enhc surrendered late",
+			rules: enhc,
+			hands: []*PlayerHand{lost(playerHandWith(10, core.Ten, core.Six), blackjack.HalfBet)},
+			want:  []int{0},
+		},
+		{
+			name:  "obo surrendered late",
+			rules: obo,
+			hands: []*PlayerHand{lost(playerHandWith(10, core.Ten, core.Six), blackjack.HalfBet)},
+			want:  []int{0},
+		},
+		{
+			name:  "enhc charlie",
+			rules: enhc,
+			hands: []*PlayerHand{wonByCharlie(playerHandWith(10, core.Two, core.Two, core.Two, core.Two, core.Two))},
+			want:  []int{20},
+		},
+		{
+			name:  "obo split charlie",
+			rules: obo,
+			hands: []*PlayerHand{wonByCharlie(splitHandWith(10, core.Two, core.Two, core.Two, core.Two, core.Two)), splitHandWith(10, core.Two, core.Ten)},
+			want:  []int{20, 0},
+		},
+		{
+			name:  "enhc natural",
+			rules: enhc,
+			hands: []*PlayerHand{playerHandWith(10, core.Ace, core.King)},
+			want:  []int{10},
+		},
+		{
+			name:  "enhc split 21",
+			rules: enhc,
+			hands: []*PlayerHand{splitHandWith(10, core.Ace, core.King), splitHandWith(10, core.Ace, core.Nine)},
+			want:  []int{0, 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			player := &Player{initialBet: 10, hands: test.hands}
+			player.LoseToDealerBlackjack(test.rules)
+
+			if got := bets(player); !slices.Equal(got, test.want) {
+				t.Errorf("got bets %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCalculateHandBet(t *testing.T) {
+	push22 := SettlementRules{BlackjackPayout: blackjack.ThreeToTwo, DealerPush22: true}
+
+	tests := []struct {
+		name        string
+		rules       SettlementRules
+		dealerValue int
+		hand        *PlayerHand
+		want        int
+		wantPushed  bool
+	}{
+		{name: "win", rules: push22, dealerValue: 18, hand: playerHandWith(10, core.Ten, core.Nine), want: 20},
+		{name: "lose", rules: push22, dealerValue: 20, hand: playerHandWith(10, core.Ten, core.Nine), want: 0},
+		{name: "push", rules: push22, dealerValue: 19, hand: playerHandWith(10, core.Ten, core.Nine), want: 10},
+		{name: "dealer 22 pushes", rules: push22, dealerValue: 22, hand: playerHandWith(10, core.Ten, core.Nine), want: 10, wantPushed: true},
+		{name: "dealer 22 pushes doubled", rules: push22, dealerValue: 22, hand: playerHandWith(20, core.Five, core.Six, core.Ten), want: 20, wantPushed: true},
+		{name: "dealer 23 busts", rules: push22, dealerValue: 23, hand: playerHandWith(10, core.Ten, core.Nine), want: 20},
+		{name: "dealer 22 busts without push", rules: SettlementRules{}, dealerValue: 22, hand: playerHandWith(10, core.Ten, core.Nine), want: 20},
+		{name: "busted against 22", rules: push22, dealerValue: 22, hand: lost(playerHandWith(10, core.Ten, core.Six, core.Ten), blackjack.EvenMoney), want: 0},
+		{name: "natural pushes 22", rules: push22, dealerValue: 22, hand: playerHandWith(10, core.Ace, core.King), want: 10, wantPushed: true},
+		{
+			name:        "natural beats 22",
+			rules:       SettlementRules{BlackjackPayout: blackjack.ThreeToTwo, DealerPush22: true, NaturalBeatsDealer22: true},
+			dealerValue: 22,
+			hand:        playerHandWith(10, core.Ace, core.King),
+			want:        25,
+		},
+		{name: "natural", rules: push22, dealerValue: 20, hand: playerHandWith(10, core.Ace, core.King), want: 25},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			player := &Player{initialBet: 10, hands: []*PlayerHand{test.hand}}
+			player.CalculateHandBet(test.dealerValue, test.rules)
+
+			if got := test.hand.GetBet(); got != test.want {
+				t.Errorf("got bet %d, want %d", got, test.want)
+			}
+			if got := test.hand.IsPushed22(); got != test.wantPushed {
+				t.Errorf("IsPushed22() = %t, want %t", got, test.wantPushed)
+			}
+		})
+	}
+}
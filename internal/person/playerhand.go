@@ -10,12 +10,25 @@ type PlayerHand struct {
 	betPlaced int
 	bet       int
 	actions   []blackjack.Action
+	fromSplit bool
+	charlie   bool
+	pushed22  bool
+	// surrenderedEarly reports whether the hand was surrendered before the
+	// dealer checked for blackjack.
+	surrenderedEarly bool
+
+	deviations []string
+
+	insuranceBetPlaced int
+	insuranceBet       int
+	evenMoney          bool
 }
 
-func NewPlayerHand() *PlayerHand {
+func NewPlayerHand(splitUnlikeTens bool) *PlayerHand {
 	return &PlayerHand{
 		Hand: Hand{
-			cards: []core.Card{},
+			cards:           []core.Card{},
+			splitUnlikeTens: splitUnlikeTens,
 		},
 		bet: 0,
 	}
@@ -26,8 +39,16 @@ func (ph *PlayerHand) PlaceBet(amount int) {
 	ph.bet = amount
 }
 
-func (ph *PlayerHand) AdjustBetByRatio(ratio float64) {
-	ph.bet = int(float64(ph.bet) * ratio)
+// WinByPayout adds the winnings for the given payout to the bet, rounded down
+// to a whole unit.
+func (ph *PlayerHand) WinByPayout(payout blackjack.Payout) {
+	ph.bet += payout.Win(ph.bet)
+}
+
+// LoseByPayout removes the losses for the given payout from the bet, rounded
+// up to a whole unit.
+func (ph *PlayerHand) LoseByPayout(payout blackjack.Payout) {
+	ph.bet -= payout.Loss(ph.bet)
 }
 
 func (ph PlayerHand) GetBetPlaced() int {
@@ -38,10 +59,85 @@ func (ph PlayerHand) GetBet() int {
 	return ph.bet
 }
 
+// IsNatural checks if the hand is a blackjack dealt on the first two cards,
+// as opposed to a two-card 21 formed by splitting.
+func (ph PlayerHand) IsNatural() bool {
+	return !ph.fromSplit && ph.IsBlackjack()
+}
+
+// IsFromSplit checks if the hand was formed by splitting.
+func (ph PlayerHand) IsFromSplit() bool {
+	return ph.fromSplit
+}
+
+// IsCharlie checks if the hand won automatically by reaching the Charlie
+// number of cards without busting.
+func (ph PlayerHand) IsCharlie() bool {
+	return ph.charlie
+}
+
+// IsPushed22 checks if the hand was pushed because the dealer finished with
+// 22 under the dealer-22-push rule.
+func (ph PlayerHand) IsPushed22() bool {
+	return ph.pushed22
+}
+
+// PlaceInsurance places an insurance side wager on the hand.
+func (ph *PlayerHand) PlaceInsurance(amount int) {
+	ph.insuranceBetPlaced = amount
+	ph.insuranceBet = amount
+}
+
+// SettleInsurance pays the insurance wager 2:1 if the dealer has blackjack,
+// otherwise the wager is lost.
+func (ph *PlayerHand) SettleInsurance(dealerHasBlackjack bool) {
+	if dealerHasBlackjack {
+		ph.insuranceBet += blackjack.TwoToOne.Win(ph.insuranceBet)
+	} else {
+		ph.insuranceBet = 0
+	}
+}
+
+func (ph PlayerHand) GetInsuranceBetPlaced() int {
+	return ph.insuranceBetPlaced
+}
+
+func (ph PlayerHand) GetInsuranceBet() int {
+	return ph.insuranceBet
+}
+
+// TookEvenMoney reports whether the player took even money on a natural.
+func (ph PlayerHand) TookEvenMoney() bool {
+	return ph.evenMoney
+}
+
 func (ph *PlayerHand) AddAction(action blackjack.Action) {
 	ph.actions = append(ph.actions, action)
 }
 
+// IsSurrendered checks if the player surrendered the hand.
+func (ph PlayerHand) IsSurrendered() bool {
+	return len(ph.actions) > 0 && ph.actions[len(ph.actions)-1] == blackjack.Surrender
+}
+
+// IsSurrenderedEarly checks if the player surrendered the hand before the
+// dealer checked for blackjack.
+func (ph PlayerHand) IsSurrenderedEarly() bool {
+	return ph.surrenderedEarly
+}
+
+// AddDeviation records the name of an index that changed a decision on the
+// hand.
+func (ph *PlayerHand) AddDeviation(name string) {
+	ph.deviations = append(ph.deviations, name)
+}
+
+// GetDeviations returns the names of the indexes that changed decisions on
+// the hand.
+func (ph PlayerHand) GetDeviations() []string {
+	return ph.deviations
+}
+
 func (ph PlayerHand) GetActions() []blackjack.Action {
 	return ph.actions
 }
@@ -0,0 +1,38 @@
+package person
+
+import "github.com/jljl1337/blackjack-simulator/internal/blackjack"
+
+// SettlementRules holds the table rules used to settle the player's hands at
+// the end of a round.
+type SettlementRules struct {
+	// BlackjackPayout is the payout for a natural blackjack.
+	BlackjackPayout blackjack.Payout
+	// SuitedBlackjackPayout is the payout for a natural blackjack made of two
+	// cards of the same suit. If unset, BlackjackPayout is used instead.
+	SuitedBlackjackPayout blackjack.Payout
+	// OriginalBetsOnly limits the loss to a dealer blackjack turned over after
+	// the player has acted to the original bet, returning doubles and splits.
+	OriginalBetsOnly bool
+	// BlackjackAfterSplit treats a two-card 21 formed by splitting as a
+	// natural blackjack.
+	BlackjackAfterSplit bool
+	// DealerPush22 pushes all hands still in play when the dealer busts with
+	// exactly 22.
+	DealerPush22 bool
+	// NaturalBeatsDealer22 still pays a natural blackjack when the dealer
+	// finishes with 22 under DealerPush22.
+	NaturalBeatsDealer22 bool
+}
+
+// isBlackjack reports whether the hand is settled as a natural blackjack.
+func (sr SettlementRules) isBlackjack(hand *PlayerHand) bool {
+	return hand.IsNatural() || (sr.BlackjackAfterSplit && hand.IsBlackjack())
+}
+
+// blackjackPayout returns the payout for the given natural blackjack hand.
+func (sr SettlementRules) blackjackPayout(hand Hand) blackjack.Payout {
+	if hand.IsSuited() && !sr.SuitedBlackjackPayout.IsZero() {
+		return sr.SuitedBlackjackPayout
+	}
+	return sr.BlackjackPayout
+}
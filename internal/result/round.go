@@ -5,13 +5,21 @@ import (
 )
 
 type RoundResult struct {
-	DealerHand  person.Hand
-	PlayerHands []person.PlayerHand
-	NumHands    int
-	Balance     int
+	DealerHand         person.Hand
+	PlayerHands        []person.PlayerHand
+	NumHands           int
+	InitialBet         int
+	Observed           bool
+	Balance            int
+	InsuranceBetPlaced int
+	InsuranceBalance   int
+	EvenMoney          bool
+	RunningCount       float64
+	TrueCount          float64
+	ExcessAces         *float64
 }
 
-func NewRoundResult(dealerHand person.Hand, playerHands []*person.PlayerHand) RoundResult {
+func NewRoundResult(dealerHand person.Hand, playerHands []*person.PlayerHand, initialBet int, observed bool, runningCount, trueCount float64, excessAces *float64) RoundResult {
 	numHands := len(playerHands)
 	hands := make([]person.PlayerHand, numHands)
 	for i, hand := range playerHands {
@@ -19,14 +27,29 @@ func NewRoundResult(dealerHand person.Hand, playerHands []*person.PlayerHand) Ro
 	}
 
 	balance := 0
+	insuranceBetPlaced := 0
+	insuranceBalance := 0
+	evenMoney := false
 	for _, hand := range playerHands {
 		balance += hand.GetBet() - hand.GetBetPlaced()
+		insuranceBetPlaced += hand.GetInsuranceBetPlaced()
+		insuranceBalance += hand.GetInsuranceBet() - hand.GetInsuranceBetPlaced()
+		evenMoney = evenMoney || hand.TookEvenMoney()
 	}
+	balance += insuranceBalance
 
 	return RoundResult{
-		DealerHand:  dealerHand,
-		PlayerHands: hands,
-		NumHands:    numHands,
-		Balance:     balance,
+		DealerHand:         dealerHand,
+		PlayerHands:        hands,
+		NumHands:           numHands,
+		InitialBet:         initialBet,
+		Observed:           observed,
+		Balance:            balance,
+		InsuranceBetPlaced: insuranceBetPlaced,
+		InsuranceBalance:   insuranceBalance,
+		EvenMoney:          evenMoney,
+		RunningCount:       runningCount,
+		TrueCount:          trueCount,
+		ExcessAces:         excessAces,
 	}
 }
@@ -0,0 +1,64 @@
+package result
+
+// MinRuinWindows is the number of windows needed for the simulated risk of
+// ruin to be more than noise.
+const MinRuinWindows = 100
+
+// RuinTracker estimates the risk of ruin from the rounds played, in order.
+// The rounds are split into back-to-back windows of the horizon, and each
+// window plays a fresh bankroll until it is lost or the window ends. The
+// windows do not overlap, so their results are independent.
+type RuinTracker struct {
+	bankroll int64
+	horizon  int
+	// numRounds is the number of rounds played in the current window.
+	numRounds  int
+	balance    int64
+	ruined     bool
+	numWindows int
+	numRuined  int
+}
+
+// NewRuinTracker creates a new RuinTracker for the bankroll and the horizon in
+// rounds played.
+func NewRuinTracker(bankroll int64, horizon int) *RuinTracker {
+	return &RuinTracker{
+		bankroll: bankroll,
+		horizon:  horizon,
+		balance:  bankroll,
+	}
+}
+
+// Add plays the rounds of a shuffle, which must be added in order.
+func (t *RuinTracker) Add(shuffleResult ShuffleResult) {
+	for _, round := range shuffleResult.RoundResults {
+		if round.Observed {
+			continue
+		}
+		t.numRounds++
+
+		if !t.ruined {
+			t.balance += int64(round.Balance)
+			t.ruined = t.balance <= 0
+		}
+
+		if t.numRounds == t.horizon {
+			t.numWindows++
+			if t.ruined {
+				t.numRuined++
+			}
+			t.numRounds = 0
+			t.balance = t.bankroll
+			t.ruined = false
+		}
+	}
+}
+
+// RiskOfRuin returns the share of windows that lost the bankroll, and the
+// number of windows. The rounds after the last full window are not counted.
+func (t RuinTracker) RiskOfRuin() (float64, int) {
+	if t.numWindows == 0 {
+		return 0, 0
+	}
+	return float64(t.numRuined) / float64(t.numWindows), t.numWindows
+}
@@ -1,29 +1,43 @@
 package result
 
 type ShuffleResult struct {
-	ShuffleId    uint
-	RoundResults []RoundResult
-	NumRounds    int
-	NumHands     int
-	Balance      int
-	Error        error
+	ShuffleId          uint
+	RoundResults       []RoundResult
+	NumRounds          int
+	NumHands           int
+	Balance            int
+	InsuranceBetPlaced int
+	InsuranceBalance   int
+	NumEvenMoney       int
+	Error              error
 }
 
 func NewShuffleResult(shuffleId uint, roundResults []RoundResult) ShuffleResult {
 	numHands := 0
 	balance := 0
+	insuranceBetPlaced := 0
+	insuranceBalance := 0
+	numEvenMoney := 0
 	for _, round := range roundResults {
 		numHands += round.NumHands
 		balance += round.Balance
+		insuranceBetPlaced += round.InsuranceBetPlaced
+		insuranceBalance += round.InsuranceBalance
+		if round.EvenMoney {
+			numEvenMoney++
+		}
 	}
 
 	return ShuffleResult{
-		ShuffleId:    shuffleId,
-		RoundResults: roundResults,
-		NumRounds:    len(roundResults),
-		NumHands:     numHands,
-		Balance:      balance,
-		Error:        nil,
+		ShuffleId:          shuffleId,
+		RoundResults:       roundResults,
+		NumRounds:          len(roundResults),
+		NumHands:           numHands,
+		Balance:            balance,
+		InsuranceBetPlaced: insuranceBetPlaced,
+		InsuranceBalance:   insuranceBalance,
+		NumEvenMoney:       numEvenMoney,
+		Error:              nil,
 	}
 }
 
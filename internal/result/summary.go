@@ -0,0 +1,251 @@
+package result
+
+import (
+	"math"
+	"sort"
+)
+
+// Summary aggregates statistics over the rounds of many shuffles.
+type Summary struct {
+	NumShuffles int
+	NumRounds   int
+	// NumRoundsObserved is the number of rounds watched without betting,
+	// included in NumRounds.
+	NumRoundsObserved int
+	// NumHands is the number of hands including those after splitting.
+	NumHands int
+	// NumHandsObserved is the number of hands watched without betting,
+	// included in NumHands.
+	NumHandsObserved int
+	Balance          int64
+	TotalInitialBet  int64
+
+	InsuranceBetPlaced int64
+	InsuranceBalance   int64
+	NumEvenMoney       int
+
+	// DeviationCounts is the number of times each index changed a decision,
+	// by index name.
+	DeviationCounts map[string]int
+
+	sumSquaredBalance float64
+	trueCountBuckets  map[int]*TrueCountBucket
+}
+
+// Add adds the rounds of a shuffle to the summary.
+func (s *Summary) Add(shuffleResult ShuffleResult) {
+	s.NumShuffles++
+	s.NumRounds += shuffleResult.NumRounds
+	s.NumHands += shuffleResult.NumHands
+	s.InsuranceBetPlaced += int64(shuffleResult.InsuranceBetPlaced)
+	s.InsuranceBalance += int64(shuffleResult.InsuranceBalance)
+	s.NumEvenMoney += shuffleResult.NumEvenMoney
+
+	for _, round := range shuffleResult.RoundResults {
+		if round.Observed {
+			s.NumRoundsObserved++
+			s.NumHandsObserved += round.NumHands
+			continue
+		}
+
+		s.Balance += int64(round.Balance)
+		s.TotalInitialBet += int64(round.InitialBet)
+		s.sumSquaredBalance += float64(round.Balance) * float64(round.Balance)
+
+		if round.InitialBet > 0 {
+			trueCount := int(math.Floor(round.TrueCount))
+			if s.trueCountBuckets == nil {
+				s.trueCountBuckets = make(map[int]*TrueCountBucket)
+			}
+			if _, exists := s.trueCountBuckets[trueCount]; !exists {
+				s.trueCountBuckets[trueCount] = &TrueCountBucket{TrueCount: trueCount}
+			}
+			s.trueCountBuckets[trueCount].add(round)
+		}
+
+		for _, hand := range round.PlayerHands {
+			for _, name := range hand.GetDeviations() {
+				if s.DeviationCounts == nil {
+					s.DeviationCounts = make(map[string]int)
+				}
+				s.DeviationCounts[name]++
+			}
+		}
+	}
+}
+
+// NumRoundsPlayed returns the number of rounds the player bet on.
+func (s Summary) NumRoundsPlayed() int {
+	return s.NumRounds - s.NumRoundsObserved
+}
+
+// NumHandsPlayed returns the number of hands the player bet on, including
+// those after splitting.
+func (s Summary) NumHandsPlayed() int {
+	return s.NumHands - s.NumHandsObserved
+}
+
+// AverageInitialBet returns the average initial bet per round played.
+func (s Summary) AverageInitialBet() float64 {
+	if s.NumRoundsPlayed() == 0 {
+		return 0
+	}
+	return float64(s.TotalInitialBet) / float64(s.NumRoundsPlayed())
+}
+
+// ExpectedValue returns the balance per initial bet over the rounds played.
+func (s Summary) ExpectedValue() float64 {
+	if s.TotalInitialBet == 0 {
+		return 0
+	}
+	return float64(s.Balance) / float64(s.TotalInitialBet)
+}
+
+// ExpectedValueStandardError returns the standard error of the expected
+// value per initial bet.
+func (s Summary) ExpectedValueStandardError() float64 {
+	if s.TotalInitialBet == 0 {
+		return 0
+	}
+
+	_, variance := s.meanAndVariance()
+	return math.Sqrt(variance*float64(s.NumRoundsPlayed())) / float64(s.TotalInitialBet)
+}
+
+// WinRatePer100Rounds returns the average balance per 100 rounds played in
+// betting units.
+func (s Summary) WinRatePer100Rounds(unit int) float64 {
+	if s.NumRoundsPlayed() == 0 {
+		return 0
+	}
+	return float64(s.Balance) / float64(unit) / float64(s.NumRoundsPlayed()) * 100
+}
+
+// WinRatePer100Hands returns the average balance per 100 hands played in
+// betting units, counting each hand after splitting.
+func (s Summary) WinRatePer100Hands(unit int) float64 {
+	if s.NumHandsPlayed() == 0 {
+		return 0
+	}
+	return float64(s.Balance) / float64(unit) / float64(s.NumHandsPlayed()) * 100
+}
+
+// WinRatePerHour returns the average balance per hour of table time in
+// betting units, counting the rounds observed as well as those played.
+func (s Summary) WinRatePerHour(unit int, roundsPerHour float64) float64 {
+	if s.NumRounds == 0 {
+		return 0
+	}
+	return float64(s.Balance) / float64(unit) / float64(s.NumRounds) * roundsPerHour
+}
+
+// StandardDeviation returns the standard deviation of the balance per round
+// played in betting units.
+func (s Summary) StandardDeviation(unit int) float64 {
+	_, variance := s.meanAndVariance()
+	return math.Sqrt(variance) / float64(unit)
+}
+
+// TrueCountBuckets returns the statistics of the rounds by the true count at
+// the start of the round, rounded down, in ascending order of true count.
+func (s Summary) TrueCountBuckets() []TrueCountBucket {
+	buckets := make([]TrueCountBucket, 0, len(s.trueCountBuckets))
+	for _, bucket := range s.trueCountBuckets {
+		buckets = append(buckets, *bucket)
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].TrueCount < buckets[j].TrueCount
+	})
+	return buckets
+}
+
+// meanAndVariance returns the mean and variance of the balance per round
+// played.
+func (s Summary) meanAndVariance() (float64, float64) {
+	if s.NumRoundsPlayed() == 0 {
+		return 0, 0
+	}
+
+	n := float64(s.NumRoundsPlayed())
+	mean := float64(s.Balance) / n
+	return mean, math.Max(s.sumSquaredBalance/n-mean*mean, 0)
+}
+
+// N0 returns the number of rounds needed for the expected win to equal one
+// standard deviation of the balance.
+func (s Summary) N0() float64 {
+	mean, variance := s.meanAndVariance()
+	if mean <= 0 {
+		return math.Inf(1)
+	}
+	return variance / (mean * mean)
+}
+
+// DesirabilityIndex returns 1000 times the ratio of the mean to the standard
+// deviation of the balance per round.
+func (s Summary) DesirabilityIndex() float64 {
+	mean, variance := s.meanAndVariance()
+	if variance == 0 {
+		return 0
+	}
+	return 1000 * mean / math.Sqrt(variance)
+}
+
+// Score returns the SCORE, the square of the desirability index, which is
+// the win rate per 100 rounds for a bankroll of 10,000 at the Kelly optimal
+// bet size.
+func (s Summary) Score() float64 {
+	di := s.DesirabilityIndex()
+	return math.Copysign(di*di, di)
+}
+
+// GrowthRate returns the expected growth of the logarithm of the bankroll per
+// round played, approximated by mean/bankroll - E[balance^2]/(2*bankroll^2).
+func (s Summary) GrowthRate(bankroll float64) float64 {
+	if s.NumRoundsPlayed() == 0 {
+		return 0
+	}
+
+	mean := float64(s.Balance) / float64(s.NumRoundsPlayed())
+	meanSquare := s.sumSquaredBalance / float64(s.NumRoundsPlayed())
+	return mean/bankroll - meanSquare/(2*bankroll*bankroll)
+}
+
+// RiskOfRuin returns the closed-form probability of ever losing the bankroll,
+// exp(-2 * mean * bankroll / variance).
+func (s Summary) RiskOfRuin(bankroll float64) float64 {
+	mean, variance := s.meanAndVariance()
+	if mean <= 0 {
+		return 1
+	}
+	return math.Exp(-2 * mean * bankroll / variance)
+}
+
+// RiskOfRuinWithin returns the closed-form probability of losing the bankroll
+// within a number of rounds, approximating the balance as Brownian motion
+// with the mean and variance per round.
+func (s Summary) RiskOfRuinWithin(bankroll float64, numRounds int) float64 {
+	mean, variance := s.meanAndVariance()
+	if variance == 0 {
+		if mean*float64(numRounds) <= -bankroll {
+			return 1
+		}
+		return 0
+	}
+
+	n := float64(numRounds)
+	sd := math.Sqrt(variance * n)
+	risk := normalCDF((-bankroll-mean*n)/sd) + math.Exp(-2*mean*bankroll/variance)*normalCDF((-bankroll+mean*n)/sd)
+	if math.IsNaN(risk) || risk > 1 {
+		// The exponential overflows when the mean is far below 0
+		return 1
+	}
+	return risk
+}
+
+// normalCDF returns the cumulative distribution function of the standard
+// normal distribution.
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
@@ -0,0 +1,147 @@
+package result
+
+import (
+	"math"
+	"sort"
+)
+
+// SessionOutcome is the reason a session ended.
+type SessionOutcome string
+
+const (
+	// SessionTime ends the session after the number of hours played.
+	SessionTime SessionOutcome = "time"
+	// SessionWinGoal ends the session when the win goal is reached.
+	SessionWinGoal SessionOutcome = "winGoal"
+	// SessionStopLoss ends the session when the stop-loss is reached.
+	SessionStopLoss SessionOutcome = "stopLoss"
+	// SessionBust ends the session and the trip when the trip bankroll is
+	// lost.
+	SessionBust SessionOutcome = "bust"
+)
+
+// SessionResult is the result of a session of consecutive shuffles played
+// with the same bankroll.
+type SessionResult struct {
+	Balance   int64
+	NumRounds int
+	Outcome   SessionOutcome
+}
+
+// TripResult is the result of the sessions of a trip, which share the trip
+// bankroll.
+type TripResult struct {
+	TripId   uint
+	Sessions []SessionResult
+	Balance  int64
+	Busted   bool
+	Error    error
+}
+
+// TripSummary aggregates the results of many trips.
+type TripSummary struct {
+	NumTrips    int
+	NumSessions int
+	NumBusted   int
+
+	sessionBalances []int64
+	tripBalances    []int64
+	outcomeCounts   map[SessionOutcome]int
+}
+
+// Add adds a trip to the summary.
+func (s *TripSummary) Add(trip TripResult) {
+	if s.outcomeCounts == nil {
+		s.outcomeCounts = make(map[SessionOutcome]int)
+	}
+
+	s.NumTrips++
+	s.tripBalances = append(s.tripBalances, trip.Balance)
+	if trip.Busted {
+		s.NumBusted++
+	}
+
+	for _, session := range trip.Sessions {
+		s.NumSessions++
+		s.sessionBalances = append(s.sessionBalances, session.Balance)
+		s.outcomeCounts[session.Outcome]++
+	}
+}
+
+// OutcomeShare returns the share of sessions that ended with the outcome.
+func (s TripSummary) OutcomeShare(outcome SessionOutcome) float64 {
+	if s.NumSessions == 0 {
+		return 0
+	}
+	return float64(s.outcomeCounts[outcome]) / float64(s.NumSessions)
+}
+
+// BustShare returns the share of trips that lost the trip bankroll.
+func (s TripSummary) BustShare() float64 {
+	if s.NumTrips == 0 {
+		return 0
+	}
+	return float64(s.NumBusted) / float64(s.NumTrips)
+}
+
+// SessionBalances returns the distribution of the balance of the sessions.
+func (s TripSummary) SessionBalances() Distribution {
+	return newDistribution(s.sessionBalances)
+}
+
+// TripBalances returns the distribution of the balance of the trips.
+func (s TripSummary) TripBalances() Distribution {
+	return newDistribution(s.tripBalances)
+}
+
+// Distribution is the sorted sample of a balance.
+type Distribution struct {
+	values []int64
+}
+
+func newDistribution(values []int64) Distribution {
+	sorted := append([]int64{}, values...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return Distribution{values: sorted}
+}
+
+// Mean returns the mean of the sample.
+func (d Distribution) Mean() float64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for _, value := range d.values {
+		sum += float64(value)
+	}
+	return sum / float64(len(d.values))
+}
+
+// StandardDeviation returns the standard deviation of the sample.
+func (d Distribution) StandardDeviation() float64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+
+	mean := d.Mean()
+	sum := 0.0
+	for _, value := range d.values {
+		sum += (float64(value) - mean) * (float64(value) - mean)
+	}
+	return math.Sqrt(sum / float64(len(d.values)))
+}
+
+// Percentile returns the value below which the share p of the sample falls,
+// using the nearest rank.
+func (d Distribution) Percentile(p float64) int64 {
+	if len(d.values) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p*float64(len(d.values)))) - 1
+	rank = max(0, min(rank, len(d.values)-1))
+	return d.values[rank]
+}
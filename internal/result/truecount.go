@@ -0,0 +1,38 @@
+package result
+
+import "math"
+
+// TrueCountBucket aggregates the rounds started at a true count, rounded down.
+// The result of a round is its balance per initial bet.
+type TrueCountBucket struct {
+	TrueCount int
+	NumRounds int
+
+	sumResult        float64
+	sumSquaredResult float64
+}
+
+func (b *TrueCountBucket) add(round RoundResult) {
+	result := float64(round.Balance) / float64(round.InitialBet)
+	b.NumRounds++
+	b.sumResult += result
+	b.sumSquaredResult += result * result
+}
+
+// AverageResult returns the average result per initial bet.
+func (b TrueCountBucket) AverageResult() float64 {
+	if b.NumRounds == 0 {
+		return 0
+	}
+	return b.sumResult / float64(b.NumRounds)
+}
+
+// Variance returns the variance of the result per initial bet.
+func (b TrueCountBucket) Variance() float64 {
+	if b.NumRounds == 0 {
+		return 0
+	}
+
+	mean := b.AverageResult()
+	return math.Max(b.sumSquaredResult/float64(b.NumRounds)-mean*mean, 0)
+}
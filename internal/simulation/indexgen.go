@@ -0,0 +1,590 @@
+package simulation
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jljl1337/blackjack-simulator/internal/betting"
+	"github.com/jljl1337/blackjack-simulator/internal/blackjack"
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+	"github.com/jljl1337/blackjack-simulator/internal/counting"
+	"github.com/jljl1337/blackjack-simulator/internal/person"
+	"github.com/jljl1337/blackjack-simulator/internal/result"
+)
+
+// indexBetUnit is the bet of the rounds played to generate indexes, so that
+// half bets and 3:2 payouts are exact.
+const indexBetUnit = 100
+
+// IndexDecision is a player hand against a dealer up card to generate an
+// index for, e.g. "H16vs10".
+type IndexDecision struct {
+	PlayerHand   string
+	DealerUpCard string
+	playerCards  []core.Card
+	dealerUpCard core.Card
+}
+
+// ParseIndexDecision parses a decision in the form of an index name, e.g.
+// "H16vs10", "S18vs9" or "P10vs6", and picks two cards for the player hand.
+func ParseIndexDecision(name string, splitUnlikeTens bool) (IndexDecision, error) {
+	playerHand, dealerUpCard, found := strings.Cut(name, "vs")
+	if !found {
+		return IndexDecision{}, fmt.Errorf("invalid decision %q, expected e.g. H16vs10", name)
+	}
+
+	upCardRank, err := parseRank(dealerUpCard)
+	if err != nil {
+		return IndexDecision{}, fmt.Errorf("invalid dealer up card in decision %q: %w", name, err)
+	}
+
+	playerRanks, err := decisionRanks(playerHand)
+	if err != nil {
+		return IndexDecision{}, fmt.Errorf("invalid player hand in decision %q: %w", name, err)
+	}
+
+	decision := IndexDecision{
+		PlayerHand:   playerHand,
+		DealerUpCard: dealerUpCard,
+		dealerUpCard: core.Card{Suit: core.Spades, Rank: upCardRank},
+	}
+	for i, rank := range playerRanks {
+		// Use different suits so that the hand is never suited
+		decision.playerCards = append(decision.playerCards, core.Card{Suit: core.Suit(i), Rank: rank})
+	}
+
+	hand := decision.hand(splitUnlikeTens)
+	key := hand.ValueString()
+	if strings.HasPrefix(playerHand, "P") {
+		key, err = hand.PairString()
+		if err != nil {
+			return IndexDecision{}, err
+		}
+	}
+	if key != playerHand {
+		return IndexDecision{}, fmt.Errorf("invalid player hand in decision %q", name)
+	}
+
+	return decision, nil
+}
+
+// Name returns the name of the decision, e.g. "H16vs10".
+func (d IndexDecision) Name() string {
+	return d.PlayerHand + "vs" + d.DealerUpCard
+}
+
+// parseRank parses a card value as used in strategy charts, "2" to "10" or
+// "A".
+func parseRank(value string) (core.Rank, error) {
+	if value == "A" {
+		return core.Ace, nil
+	}
+
+	rank, err := strconv.Atoi(value)
+	if err != nil || rank < 2 || rank > 10 {
+		return 0, fmt.Errorf("invalid card value: %s", value)
+	}
+	return core.Rank(rank), nil
+}
+
+// decisionRanks returns the ranks of two cards that make the player hand,
+// e.g. 10 and 6 for H16.
+func decisionRanks(playerHand string) ([]core.Rank, error) {
+	if len(playerHand) < 2 {
+		return nil, fmt.Errorf("invalid player hand: %s", playerHand)
+	}
+
+	if playerHand[0] == 'P' {
+		rank, err := parseRank(playerHand[1:])
+		if err != nil {
+			return nil, err
+		}
+		return []core.Rank{rank, rank}, nil
+	}
+
+	total, err := strconv.Atoi(playerHand[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid player hand: %s", playerHand)
+	}
+
+	switch {
+	case playerHand[0] == 'S' && total >= 13 && total <= 20:
+		return []core.Rank{core.Ace, core.Rank(total - 11)}, nil
+	case playerHand[0] == 'H' && total >= 12 && total <= 20:
+		return []core.Rank{core.Ten, core.Rank(total - 10)}, nil
+	case playerHand[0] == 'H' && total >= 5 && total <= 11:
+		return []core.Rank{core.Rank(total - 2), core.Two}, nil
+	default:
+		return nil, fmt.Errorf("invalid player hand: %s", playerHand)
+	}
+}
+
+// indexJob is a decision to play at a true count.
+type indexJob struct {
+	decision  int
+	trueCount int
+	seed      int64
+}
+
+// actionPair is an alternative action and the action it is compared with.
+type actionPair struct {
+	alternative blackjack.Action
+	base        blackjack.Action
+}
+
+// indexJobResult holds the expected value per initial bet of each action of
+// an index job, and the standard error of the difference in EV of each pair
+// of actions, which are played on the same cards.
+type indexJobResult struct {
+	job            indexJob
+	evs            map[blackjack.Action]float64
+	standardErrors map[actionPair]float64
+	err            error
+}
+
+// generateIndexes finds the break-even true count of each decision under the
+// rules and writes them to an index table file. Each decision is played in
+// shoes whose composition matches each true count in the range, with every
+// legal first action played on the same cards. The basic strategy is used for
+// any later decisions.
+func (s *Simulator) generateIndexes() error {
+	startTime := time.Now()
+
+	trueCounts := make([]int, 0, s.indexMaxTrueCount-s.indexMinTrueCount+1)
+	for trueCount := s.indexMinTrueCount; trueCount <= s.indexMaxTrueCount; trueCount++ {
+		trueCounts = append(trueCounts, trueCount)
+	}
+
+	jobChan := make(chan indexJob, s.numWorkers)
+	resultChan := make(chan indexJobResult, s.numWorkers)
+
+	for range s.numWorkers {
+		go func() {
+			for job := range jobChan {
+				resultChan <- s.playIndexJob(job)
+			}
+		}()
+	}
+
+	go func() {
+		for decision := range s.indexDecisions {
+			// The true counts of a decision share a seed, so their shoes are
+			// shuffled alike and the noise in the EVs is correlated across
+			// true counts rather than independent
+			seed := s.seed + int64(decision)
+			for _, trueCount := range trueCounts {
+				jobChan <- indexJob{decision: decision, trueCount: trueCount, seed: seed}
+			}
+		}
+		close(jobChan)
+	}()
+
+	// evs[decision][trueCount - min] is the EV of each action, and
+	// standardErrors[decision][trueCount - min] the standard error of the
+	// difference of each pair of actions
+	evs := make([][]map[blackjack.Action]float64, len(s.indexDecisions))
+	standardErrors := make([][]map[actionPair]float64, len(s.indexDecisions))
+	for i := range evs {
+		evs[i] = make([]map[blackjack.Action]float64, len(trueCounts))
+		standardErrors[i] = make([]map[actionPair]float64, len(trueCounts))
+	}
+
+	var firstErr error
+	for range len(s.indexDecisions) * len(trueCounts) {
+		jobResult := <-resultChan
+		if jobResult.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error playing %s at true count %d: %w", s.indexDecisions[jobResult.job.decision].Name(), jobResult.job.trueCount, jobResult.err)
+			}
+			continue
+		}
+		evs[jobResult.job.decision][jobResult.job.trueCount-s.indexMinTrueCount] = jobResult.evs
+		standardErrors[jobResult.job.decision][jobResult.job.trueCount-s.indexMinTrueCount] = jobResult.standardErrors
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	log.Printf("Played %d decisions at %d true counts using %.3f seconds\n", len(s.indexDecisions), len(trueCounts), time.Since(startTime).Seconds())
+
+	records := [][]string{{"PlayerHand", "DealerUpCard", "TrueCount", "Actions"}}
+	for i, decision := range s.indexDecisions {
+		baseAction, err := s.indexBaseAction(decision)
+		if err != nil {
+			return err
+		}
+
+		if s.verbose {
+			for j, trueCount := range trueCounts {
+				log.Printf("%s at true count %d: %s\n", decision.Name(), trueCount, formatEVs(evs[i][j]))
+			}
+		}
+
+		index, breakEven, standardError, found, err := findIndex(decision, baseAction, trueCounts, evs[i], standardErrors[i])
+		if err != nil {
+			return err
+		}
+		if !found {
+			log.Printf("%s: basic strategy %s, no deviation between true counts %d and %d\n", decision.Name(), baseAction, s.indexMinTrueCount, s.indexMaxTrueCount)
+			continue
+		}
+
+		if math.IsNaN(standardError) {
+			log.Printf("%s: basic strategy %s, %s at %s (break-even at the end of the true counts played)\n", decision.Name(), baseAction, index.Actions[0], index.Condition())
+		} else {
+			log.Printf("%s: basic strategy %s, %s at %s (break-even at %.2f, standard error %.2f)\n", decision.Name(), baseAction, index.Actions[0], index.Condition(), breakEven, standardError)
+		}
+
+		records = append(records, []string{
+			index.PlayerHand,
+			index.DealerUpCard,
+			index.Condition(),
+			index.Actions[0].String(),
+		})
+	}
+
+	file, err := os.Create(s.indexOutputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("error writing index table: %w", err)
+	}
+
+	log.Printf("Index table written to %s\n", s.indexOutputFile)
+	return nil
+}
+
+// playIndexJob plays the decision of the job in shoes at the true count and
+// returns the average result of each first action.
+func (s *Simulator) playIndexJob(job indexJob) indexJobResult {
+	random := rand.New(rand.NewSource(job.seed))
+	decision := s.indexDecisions[job.decision]
+
+	actions, err := s.indexActions(decision)
+	if err != nil {
+		return indexJobResult{job: job, err: err}
+	}
+
+	known := append([]core.Card{decision.dealerUpCard}, decision.playerCards...)
+	totals := make(map[blackjack.Action]int64, len(actions))
+	balances := make(map[blackjack.Action]int, len(actions))
+	// sumSquaredDiffs is the sum of the squared differences in balance of each
+	// pair of actions played on the same cards
+	sumSquaredDiffs := make(map[actionPair]float64)
+
+	for trial := 0; trial < s.indexTrials; {
+		shoe, err := newShoeAtTrueCount(s.countingSystem, s.numDecks, s.penetration, float64(job.trueCount), known, random)
+		if err != nil {
+			return indexJobResult{job: job, err: err}
+		}
+
+		var holeCard *core.Card
+		if s.rules.DealerPeeks() {
+			card := shoe.Deal()
+			if (card.IsTenValue() && decision.dealerUpCard.Rank == core.Ace) || (card.Rank == core.Ace && decision.dealerUpCard.IsTenValue()) {
+				// The decision is only made if the dealer has no blackjack
+				continue
+			}
+			holeCard = &card
+		}
+
+		for _, action := range actions {
+			balance, err := s.playIndexRound(decision, holeCard, *shoe, action)
+			if err != nil {
+				return indexJobResult{job: job, err: err}
+			}
+			totals[action] += int64(balance)
+			balances[action] = balance
+		}
+		for _, alternative := range actions {
+			for _, base := range actions {
+				diff := float64(balances[alternative] - balances[base])
+				sumSquaredDiffs[actionPair{alternative: alternative, base: base}] += diff * diff
+			}
+		}
+		trial++
+	}
+
+	numTrials := float64(s.indexTrials)
+	evs := make(map[blackjack.Action]float64, len(actions))
+	for _, action := range actions {
+		evs[action] = float64(totals[action]) / numTrials / indexBetUnit
+	}
+
+	standardErrors := make(map[actionPair]float64, len(sumSquaredDiffs))
+	for pair, sumSquaredDiff := range sumSquaredDiffs {
+		meanDiff := (evs[pair.alternative] - evs[pair.base]) * indexBetUnit
+		variance := math.Max(sumSquaredDiff/numTrials-meanDiff*meanDiff, 0)
+		standardErrors[pair] = math.Sqrt(variance/numTrials) / indexBetUnit
+	}
+
+	return indexJobResult{job: job, evs: evs, standardErrors: standardErrors}
+}
+
+// playIndexRound plays a round of the decision with the first action forced,
+// and returns the balance of the round.
+func (s *Simulator) playIndexRound(decision IndexDecision, holeCard *core.Card, shoe core.Shoe, firstAction blackjack.Action) (int, error) {
+	flatBet, err := betting.NewFlatBet(indexBetUnit)
+	if err != nil {
+		return 0, err
+	}
+
+	player := person.NewPlayer(s.strategy, blackjack.NeverInsurance{}, flatBet, nil, nil, s.rules.SplitUnlikeTens())
+	if err := player.PlaceBet(0, true); err != nil {
+		return 0, err
+	}
+	for _, card := range decision.playerCards {
+		if err := player.DrawCard(card); err != nil {
+			return 0, err
+		}
+	}
+
+	dealer := person.NewDealer()
+	dealer.DrawCard(decision.dealerUpCard)
+	if holeCard != nil {
+		dealer.DrawCard(*holeCard)
+	}
+
+	deal := func() core.Card {
+		return shoe.Deal()
+	}
+	// Later decisions follow the basic strategy, the player has no indexes
+	shoeState := func() blackjack.ShoeState {
+		unseenCards := shoe.RankCounts()
+		if holeCard != nil {
+			unseenCards[holeCard.Rank]++
+		}
+		return blackjack.ShoeState{UnseenCards: unseenCards}
+	}
+
+	if err := playPlayerHands(player, decision.dealerUpCard, s.rules, deal, shoeState, firstAction); err != nil {
+		return 0, err
+	}
+	if err := playDealerHand(player, dealer, s.rules, deal, false); err != nil {
+		return 0, err
+	}
+
+	return result.NewRoundResult(dealer.GetHand(), player.GetHands(), player.GetInitialBet(), false, 0, 0, nil).Balance, nil
+}
+
+// indexActions returns the legal first actions of the decision. Splitting is
+// only considered for pair decisions.
+func (s *Simulator) indexActions(decision IndexDecision) ([]blackjack.Action, error) {
+	actionsAllowed, err := s.rules.GetActionsAllowed(decision.hand(s.rules.SplitUnlikeTens()).Hand, 1, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var actions []blackjack.Action
+	for _, action := range []blackjack.Action{blackjack.Hit, blackjack.Stand, blackjack.Double, blackjack.Split, blackjack.Surrender} {
+		if action == blackjack.Split && !strings.HasPrefix(decision.PlayerHand, "P") {
+			continue
+		}
+		if actionsAllowed[action] {
+			actions = append(actions, action)
+		}
+	}
+	return actions, nil
+}
+
+// indexBaseAction returns the basic strategy action of the decision.
+func (s *Simulator) indexBaseAction(decision IndexDecision) (blackjack.Action, error) {
+	actions, err := s.indexActions(decision)
+	if err != nil {
+		return blackjack.NA, err
+	}
+
+	actionsAllowed := make(map[blackjack.Action]bool, len(actions))
+	for _, action := range actions {
+		actionsAllowed[action] = true
+	}
+
+	return s.strategy.Decide(blackjack.DecisionContext{
+		PlayerHand:     decision.hand(s.rules.SplitUnlikeTens()),
+		DealerUpCard:   decision.dealerUpCard,
+		ActionsAllowed: actionsAllowed,
+		NumHands:       1,
+	})
+}
+
+// hand returns the player hand of the decision.
+func (d IndexDecision) hand(splitUnlikeTens bool) *person.PlayerHand {
+	hand := person.NewPlayerHand(splitUnlikeTens)
+	for _, card := range d.playerCards {
+		hand.AddCard(card)
+	}
+	return hand
+}
+
+// findIndex finds the true count at which the best alternative to the basic
+// strategy action breaks even with it, by linear interpolation between the
+// true counts played, and its standard error from those of the EV
+// differences around it. The standard error is NaN if the break-even is at
+// the end of the true counts played. The alternative is the action that is
+// best most often where the basic strategy action is not.
+func findIndex(decision IndexDecision, baseAction blackjack.Action, trueCounts []int, evs []map[blackjack.Action]float64, standardErrors []map[actionPair]float64) (blackjack.Index, float64, float64, bool, error) {
+	bestCounts := make(map[blackjack.Action]int)
+	alternative := blackjack.NA
+	for _, actionEVs := range evs {
+		best := bestAction(actionEVs)
+		if best == baseAction {
+			continue
+		}
+		bestCounts[best]++
+		if alternative == blackjack.NA || bestCounts[best] > bestCounts[alternative] {
+			alternative = best
+		}
+	}
+	if alternative == blackjack.NA {
+		return blackjack.Index{}, 0, 0, false, nil
+	}
+
+	pair := actionPair{alternative: alternative, base: baseAction}
+	diffs := make([]float64, len(evs))
+	diffErrors := make([]float64, len(evs))
+	for i, actionEVs := range evs {
+		diffs[i] = actionEVs[alternative] - actionEVs[baseAction]
+		diffErrors[i] = standardErrors[i][pair]
+	}
+
+	last := len(diffs) - 1
+	var operator string
+	breakEven, standardError := 0.0, math.NaN()
+	switch {
+	case diffs[last] > 0:
+		// The alternative is better at high counts
+		operator = ">="
+		breakEven = float64(trueCounts[0])
+		for i := last - 1; i >= 0; i-- {
+			if diffs[i] <= 0 {
+				breakEven, standardError = interpolate(trueCounts[i], trueCounts[i+1], diffs[i], diffs[i+1], diffErrors[i], diffErrors[i+1])
+				break
+			}
+		}
+	case diffs[0] > 0:
+		// The alternative is better at low counts
+		operator = "<"
+		breakEven = float64(trueCounts[last])
+		for i := 1; i <= last; i++ {
+			if diffs[i] <= 0 {
+				breakEven, standardError = interpolate(trueCounts[i-1], trueCounts[i], diffs[i-1], diffs[i], diffErrors[i-1], diffErrors[i])
+				break
+			}
+		}
+	default:
+		return blackjack.Index{}, 0, 0, false, nil
+	}
+
+	condition := operator + strconv.FormatFloat(math.Round(breakEven), 'f', -1, 64)
+	index, err := blackjack.NewIndex(decision.PlayerHand, decision.DealerUpCard, condition, []blackjack.Action{alternative})
+	if err != nil {
+		return blackjack.Index{}, 0, 0, false, err
+	}
+	return index, breakEven, standardError, true, nil
+}
+
+// interpolate returns the true count between x0 and x1 where the EV
+// difference crosses zero, and its standard error from the standard errors
+// e0 and e1 of the differences y0 and y1, divided by the slope of the
+// difference.
+func interpolate(x0, x1 int, y0, y1, e0, e1 float64) (float64, float64) {
+	if y1 == y0 {
+		return float64(x0), math.Inf(1)
+	}
+
+	slope := (y1 - y0) / float64(x1-x0)
+	return float64(x0) - y0/slope, (e0 + e1) / 2 / math.Abs(slope)
+}
+
+// bestAction returns the action with the highest EV.
+func bestAction(evs map[blackjack.Action]float64) blackjack.Action {
+	best := blackjack.NA
+	for _, action := range []blackjack.Action{blackjack.Hit, blackjack.Stand, blackjack.Double, blackjack.Split, blackjack.Surrender} {
+		ev, exists := evs[action]
+		if exists && (best == blackjack.NA || ev > evs[best]) {
+			best = action
+		}
+	}
+	return best
+}
+
+// formatEVs formats the EV of each action, e.g. "H=-0.5432 S=-0.5401".
+func formatEVs(evs map[blackjack.Action]float64) string {
+	parts := make([]string, 0, len(evs))
+	for _, action := range []blackjack.Action{blackjack.Hit, blackjack.Stand, blackjack.Double, blackjack.Split, blackjack.Surrender} {
+		if ev, exists := evs[action]; exists {
+			parts = append(parts, fmt.Sprintf("%s=%.4f", action, ev))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// newShoeAtTrueCount returns a shoe of the cards remaining halfway through
+// the penetration, after the known cards and other seen cards were dealt,
+// with the seen cards chosen such that the true count is close to the
+// target.
+func newShoeAtTrueCount(system counting.System, numDecks uint, penetration, trueCount float64, known []core.Card, random *rand.Rand) (*core.Shoe, error) {
+	cards := make([]core.Card, 0, numDecks*52)
+	for range numDecks {
+		cards = append(cards, core.NewDeck()...)
+	}
+
+	runningCount := system.InitialRunningCount(numDecks)
+	for _, knownCard := range known {
+		for i, card := range cards {
+			if card.Rank == knownCard.Rank {
+				cards = append(cards[:i], cards[i+1:]...)
+				break
+			}
+		}
+		runningCount += system.Tags.Tag(knownCard.Rank)
+	}
+
+	random.Shuffle(len(cards), func(i, j int) {
+		cards[i], cards[j] = cards[j], cards[i]
+	})
+
+	numSeen := max(int(float64(numDecks*52)*penetration/2)-len(known), 0)
+	seen, unseen := cards[:numSeen], cards[numSeen:]
+	for _, card := range seen {
+		runningCount += system.Tags.Tag(card.Rank)
+	}
+
+	target := trueCount
+	if system.IsBalanced() {
+		target *= float64(len(unseen)) / 52.0
+	}
+
+	// Swapping a seen and an unseen card moves the running count by at most
+	// twice the largest tag, so the count can always get this close
+	tolerance := 0.0
+	for rank := core.Ace; rank <= core.King; rank++ {
+		tolerance = math.Max(tolerance, math.Abs(system.Tags.Tag(rank)))
+	}
+
+	for attempts := 0; math.Abs(runningCount-target) > tolerance; attempts++ {
+		if attempts >= 100*len(cards) || len(seen) == 0 {
+			return nil, fmt.Errorf("cannot reach true count %g", trueCount)
+		}
+
+		i, j := random.Intn(len(seen)), random.Intn(len(unseen))
+		delta := system.Tags.Tag(unseen[j].Rank) - system.Tags.Tag(seen[i].Rank)
+		if (runningCount < target && delta > 0) || (runningCount > target && delta < 0) {
+			seen[i], unseen[j] = unseen[j], seen[i]
+			runningCount += delta
+		}
+	}
+
+	return core.NewShoeFromCards(unseen, numDecks, penetration), nil
+}
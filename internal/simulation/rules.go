@@ -1,48 +1,332 @@
 package simulation
 
 import (
+	"fmt"
+
 	"github.com/jljl1337/blackjack-simulator/internal/blackjack"
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+	"github.com/jljl1337/blackjack-simulator/internal/person"
 )
 
+// HoleCardRule determines when the dealer takes the second card and how a
+// dealer blackjack is settled.
+type HoleCardRule string
+
+const (
+	// HoleCardPeek deals the hole card up front and the dealer peeks for
+	// blackjack before the player acts, as in US games.
+	HoleCardPeek HoleCardRule = "peek"
+	// NoHoleCard deals the dealer's second card after the player has acted,
+	// as in European games. All wagers, including doubles and splits, are lost
+	// to a dealer blackjack.
+	NoHoleCard HoleCardRule = "enhc"
+	// NoHoleCardOBO is NoHoleCard where only the original bet is lost to a
+	// dealer blackjack, and doubles and splits are returned.
+	NoHoleCardOBO HoleCardRule = "enhcObo"
+)
+
+// ParseHoleCardRule parses a hole card rule from its config name.
+func ParseHoleCardRule(rule string) (HoleCardRule, error) {
+	switch HoleCardRule(rule) {
+	case HoleCardPeek, NoHoleCard, NoHoleCardOBO:
+		return HoleCardRule(rule), nil
+	default:
+		return "", fmt.Errorf("invalid hole card rule: %s", rule)
+	}
+}
+
+// SurrenderRule determines when the player may surrender.
+type SurrenderRule string
+
+const (
+	// SurrenderNone does not allow surrender.
+	SurrenderNone SurrenderRule = "none"
+	// SurrenderLate allows surrender on the first two cards after the dealer
+	// has checked for blackjack.
+	SurrenderLate SurrenderRule = "late"
+	// SurrenderEarly allows surrender on the first two cards before the
+	// dealer checks for blackjack against a ten or an ace.
+	SurrenderEarly SurrenderRule = "early"
+	// SurrenderEarlyTen allows early surrender against a ten and late
+	// surrender against any other up card.
+	SurrenderEarlyTen SurrenderRule = "earlyTen"
+	// SurrenderEarlyAce allows early surrender against an ace and late
+	// surrender against any other up card.
+	SurrenderEarlyAce SurrenderRule = "earlyAce"
+)
+
+// ParseSurrenderRule parses a surrender rule from its config name.
+func ParseSurrenderRule(rule string) (SurrenderRule, error) {
+	switch SurrenderRule(rule) {
+	case SurrenderNone, SurrenderLate, SurrenderEarly, SurrenderEarlyTen, SurrenderEarlyAce:
+		return SurrenderRule(rule), nil
+	default:
+		return "", fmt.Errorf("invalid surrender rule: %s", rule)
+	}
+}
+
+// DoubleRule determines which hands the player may double down on.
+type DoubleRule string
+
+const (
+	// DoubleAnyTwo allows doubling on any first two cards.
+	DoubleAnyTwo DoubleRule = "anyTwo"
+	// DoubleNineToEleven allows doubling on a hard 9, 10 or 11 only.
+	DoubleNineToEleven DoubleRule = "9-11"
+	// DoubleTenToEleven allows doubling on a hard 10 or 11 only.
+	DoubleTenToEleven DoubleRule = "10-11"
+	// DoubleAny allows doubling on any number of cards, as in Spanish 21.
+	DoubleAny DoubleRule = "any"
+)
+
+// ParseDoubleRule parses a double rule from its config name.
+func ParseDoubleRule(rule string) (DoubleRule, error) {
+	switch DoubleRule(rule) {
+	case DoubleAnyTwo, DoubleNineToEleven, DoubleTenToEleven, DoubleAny:
+		return DoubleRule(rule), nil
+	default:
+		return "", fmt.Errorf("invalid double rule: %s", rule)
+	}
+}
+
+// canDouble reports whether the rule allows doubling on the hand.
+func (dr DoubleRule) canDouble(hand person.Hand) bool {
+	switch dr {
+	case DoubleNineToEleven:
+		return hand.GetSize() == 2 && !hand.IsSoft() && hand.Value() >= 9 && hand.Value() <= 11
+	case DoubleTenToEleven:
+		return hand.GetSize() == 2 && !hand.IsSoft() && hand.Value() >= 10 && hand.Value() <= 11
+	case DoubleAny:
+		return hand.GetSize() >= 2
+	default:
+		return hand.GetSize() == 2
+	}
+}
+
 // Rules implements the rules of the game for a single round of blackjack.
 type Rules struct {
-	doubleAfterSplit    bool
-	hitAfterSplitAce    bool
-	splitAfterSplitAce  bool
-	doubleAfterSplitAce bool
-	maxNumHands         int
-	surrenderAllowed    bool
+	doubleRule            DoubleRule
+	doubleAfterSplit      bool
+	hitAfterSplitAce      bool
+	splitAfterSplitAce    bool
+	doubleAfterSplitAce   bool
+	maxNumHands           int
+	maxNumHandsByRank     map[string]int
+	splitUnlikeTens       bool
+	surrenderRule         SurrenderRule
+	surrenderAfterSplit   bool
+	surrenderAfterDouble  bool
+	dealerHitsSoft17      bool
+	blackjackPayout       blackjack.Payout
+	suitedBlackjackPayout blackjack.Payout
+	blackjackAfterSplit   bool
+	holeCardRule          HoleCardRule
+	charlieCards          int
+	charliePayout         blackjack.Payout
+	dealerPush22          bool
+	naturalBeatsDealer22  bool
 }
 
-// NewRules creates a new instance of PlayRules.
-func NewRules(doubleAfterSplit, hitAfterSplitAce, splitAfterSplitAce, doubleAfterSplitAce bool, maxNumHands int, surrenderAllowed bool) Rules {
+// RulesOptions are the table rules of a round, as parsed from the Config.
+type RulesOptions struct {
+	DoubleRule          DoubleRule
+	DoubleAfterSplit    bool
+	HitAfterSplitAce    bool
+	SplitAfterSplitAce  bool
+	DoubleAfterSplitAce bool
+	// MaxNumHands is the number of hands the player may split to, and
+	// MaxNumHandsByRank overrides it by the rank of the pair, e.g. "A".
+	MaxNumHands           int
+	MaxNumHandsByRank     map[string]int
+	SplitUnlikeTens       bool
+	SurrenderRule         SurrenderRule
+	SurrenderAfterSplit   bool
+	SurrenderAfterDouble  bool
+	DealerHitsSoft17      bool
+	BlackjackPayout       blackjack.Payout
+	SuitedBlackjackPayout blackjack.Payout
+	BlackjackAfterSplit   bool
+	HoleCardRule          HoleCardRule
+	// CharlieCards is the number of cards of a Charlie, 0 for no Charlie.
+	CharlieCards         int
+	CharliePayout        blackjack.Payout
+	DealerPush22         bool
+	NaturalBeatsDealer22 bool
+}
+
+// NewRules creates a new instance of Rules from the options.
+func NewRules(options RulesOptions) Rules {
 	return Rules{
-		doubleAfterSplit:    doubleAfterSplit,
-		hitAfterSplitAce:    hitAfterSplitAce,
-		splitAfterSplitAce:  splitAfterSplitAce,
-		doubleAfterSplitAce: doubleAfterSplitAce,
-		maxNumHands:         maxNumHands,
-		surrenderAllowed:    surrenderAllowed,
+		doubleRule:            options.DoubleRule,
+		doubleAfterSplit:      options.DoubleAfterSplit,
+		hitAfterSplitAce:      options.HitAfterSplitAce,
+		splitAfterSplitAce:    options.SplitAfterSplitAce,
+		doubleAfterSplitAce:   options.DoubleAfterSplitAce,
+		maxNumHands:           options.MaxNumHands,
+		maxNumHandsByRank:     options.MaxNumHandsByRank,
+		splitUnlikeTens:       options.SplitUnlikeTens,
+		surrenderRule:         options.SurrenderRule,
+		surrenderAfterSplit:   options.SurrenderAfterSplit,
+		surrenderAfterDouble:  options.SurrenderAfterDouble,
+		dealerHitsSoft17:      options.DealerHitsSoft17,
+		blackjackPayout:       options.BlackjackPayout,
+		suitedBlackjackPayout: options.SuitedBlackjackPayout,
+		blackjackAfterSplit:   options.BlackjackAfterSplit,
+		holeCardRule:          options.HoleCardRule,
+		charlieCards:          options.CharlieCards,
+		charliePayout:         options.CharliePayout,
+		dealerPush22:          options.DealerPush22,
+		naturalBeatsDealer22:  options.NaturalBeatsDealer22,
 	}
 }
 
-func (r Rules) GetActionsAllowed(currentHandSize int, numHands int, splitAce bool) (map[blackjack.Action]bool, error) {
+// DealerHitsSoft17 reports whether the dealer hits on soft 17.
+func (r Rules) DealerHitsSoft17() bool {
+	return r.dealerHitsSoft17
+}
+
+// DealerRule returns the short name of the dealer's soft 17 rule, either
+// "H17" or "S17".
+func (r Rules) DealerRule() string {
+	if r.dealerHitsSoft17 {
+		return "H17"
+	}
+	return "S17"
+}
+
+// HoleCardRule returns the rule for dealing the dealer's second card.
+func (r Rules) HoleCardRule() HoleCardRule {
+	return r.holeCardRule
+}
+
+// DealerPeeks reports whether the dealer takes a hole card and checks for
+// blackjack before the player acts.
+func (r Rules) DealerPeeks() bool {
+	return r.holeCardRule == HoleCardPeek
+}
+
+// SurrenderRule returns the rule for when the player may surrender.
+func (r Rules) SurrenderRule() SurrenderRule {
+	return r.surrenderRule
+}
+
+// CanSurrenderEarly reports whether the player may surrender against the
+// dealer's up card before the dealer checks for blackjack.
+func (r Rules) CanSurrenderEarly(dealerUpCard core.Card) bool {
+	switch r.surrenderRule {
+	case SurrenderEarly:
+		return dealerUpCard.IsTenValue() || dealerUpCard.Rank == core.Ace
+	case SurrenderEarlyTen:
+		return dealerUpCard.IsTenValue()
+	case SurrenderEarlyAce:
+		return dealerUpCard.Rank == core.Ace
+	default:
+		return false
+	}
+}
+
+// CanSurrenderAfterDouble reports whether the player may surrender a hand
+// after doubling down, losing only the original bet.
+func (r Rules) CanSurrenderAfterDouble() bool {
+	return r.surrenderRule != SurrenderNone && r.surrenderAfterDouble
+}
+
+func (r Rules) BlackjackPayout() blackjack.Payout {
+	return r.blackjackPayout
+}
+
+func (r Rules) SuitedBlackjackPayout() blackjack.Payout {
+	return r.suitedBlackjackPayout
+}
+
+// DoubleRule returns the rule for which hands the player may double down on.
+func (r Rules) DoubleRule() DoubleRule {
+	return r.doubleRule
+}
+
+// BlackjackAfterSplit reports whether a two-card 21 formed by splitting is
+// treated as a natural blackjack.
+func (r Rules) BlackjackAfterSplit() bool {
+	return r.blackjackAfterSplit
+}
+
+// SplitUnlikeTens reports whether any two ten-value cards may be split, e.g.
+// K-Q, instead of only two cards of the same rank.
+func (r Rules) SplitUnlikeTens() bool {
+	return r.splitUnlikeTens
+}
+
+// maxNumHandsForPair returns the maximum number of hands the player may have
+// after splitting the given pair, which may be limited per rank.
+func (r Rules) maxNumHandsForPair(pair person.Hand) int {
+	pairString, err := pair.PairString()
+	if err != nil {
+		return r.maxNumHands
+	}
+
+	if maxNumHands, exists := r.maxNumHandsByRank[pairString[1:]]; exists {
+		return maxNumHands
+	}
+	return r.maxNumHands
+}
+
+// CharlieCards returns the number of cards that wins a hand automatically, or
+// 0 if there is no Charlie rule.
+func (r Rules) CharlieCards() int {
+	return r.charlieCards
+}
+
+// CharliePayout returns the payout for a Charlie hand.
+func (r Rules) CharliePayout() blackjack.Payout {
+	return r.charliePayout
+}
+
+// IsCharlie reports whether the hand wins automatically by reaching the
+// Charlie number of cards without busting.
+func (r Rules) IsCharlie(hand person.Hand) bool {
+	return r.charlieCards > 0 && hand.GetSize() >= r.charlieCards && !hand.IsBusted()
+}
+
+// DealerPush22 reports whether a dealer total of 22 pushes the hands still in
+// play instead of losing to them.
+func (r Rules) DealerPush22() bool {
+	return r.dealerPush22
+}
+
+func (r Rules) GetActionsAllowed(currentHand person.Hand, numHands int, splitAce bool) (map[blackjack.Action]bool, error) {
 	// This method should return the actions available to the player.
 
 	// Check if hit is allowed for split aces
 	canHit := !splitAce || r.hitAfterSplitAce
 
 	// Check if double is allowed
-	canDouble := currentHandSize == 2 && (!splitAce || r.doubleAfterSplitAce) && (numHands < 2 || r.doubleAfterSplit)
+	canDouble := r.doubleRule.canDouble(currentHand) && (!splitAce || r.doubleAfterSplitAce) && (numHands < 2 || r.doubleAfterSplit)
 
 	// Check if split is allowed
-	canSplit := ((!splitAce || r.splitAfterSplitAce) && numHands < r.maxNumHands) || r.maxNumHands < 0
+	maxNumHands := r.maxNumHandsForPair(currentHand)
+	canSplit := currentHand.IsPair() && (!splitAce || r.splitAfterSplitAce) && (numHands < maxNumHands || maxNumHands < 0)
+
+	// Check if surrender is allowed, only on the first two cards
+	canSurrender := r.surrenderRule != SurrenderNone && currentHand.GetSize() == 2 && (numHands < 2 || r.surrenderAfterSplit)
 
 	return map[blackjack.Action]bool{
 		blackjack.Hit:       canHit,
 		blackjack.Stand:     true,
 		blackjack.Double:    canDouble,
 		blackjack.Split:     canSplit,
-		blackjack.Surrender: r.surrenderAllowed,
+		blackjack.Surrender: canSurrender,
 	}, nil
 }
+
+// SettlementRules returns the rules the player uses to settle hands at the end
+// of a round.
+func (r Rules) SettlementRules() person.SettlementRules {
+	return person.SettlementRules{
+		BlackjackPayout:       r.blackjackPayout,
+		SuitedBlackjackPayout: r.suitedBlackjackPayout,
+		OriginalBetsOnly:      r.holeCardRule == NoHoleCardOBO,
+		BlackjackAfterSplit:   r.blackjackAfterSplit,
+		DealerPush22:          r.dealerPush22,
+		NaturalBeatsDealer22:  r.naturalBeatsDealer22,
+	}
+}
@@ -0,0 +1,230 @@
+package simulation
+
+import (
+	"testing"
+
+	"github.com/jljl1337/blackjack-simulator/internal/blackjack"
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+	"github.com/jljl1337/blackjack-simulator/internal/person"
+)
+
+// testRulesOptions returns common table rules: S17, double any two and after
+// splits, split to 4 hands, no surrender, 3:2 blackjack and a peek.
+func testRulesOptions() RulesOptions {
+	return RulesOptions{
+		DoubleRule:       DoubleAnyTwo,
+		DoubleAfterSplit: true,
+		MaxNumHands:      4,
+		SurrenderRule:    SurrenderNone,
+		BlackjackPayout:  blackjack.ThreeToTwo,
+		HoleCardRule:     HoleCardPeek,
+	}
+}
+
+func TestRulesHoleCard(t *testing.T) {
+	tests := []struct {
+		rule             HoleCardRule
+		dealerPeeks      bool
+		originalBetsOnly bool
+	}{
+		{rule: HoleCardPeek, dealerPeeks: true, originalBetsOnly: false},
+		{rule: NoHoleCard, dealerPeeks: false, originalBetsOnly: false},
+		{rule: NoHoleCardOBO, dealerPeeks: false, originalBetsOnly: true},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.rule), func(t *testing.T) {
+			rule, err := ParseHoleCardRule(string(test.rule))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			options := testRulesOptions()
+			options.HoleCardRule = rule
+			rules := NewRules(options)
+
+			if got := rules.DealerPeeks(); got != test.dealerPeeks {
+				t.Errorf("DealerPeeks() = %t, want %t", got, test.dealerPeeks)
+			}
+			if got := rules.SettlementRules().OriginalBetsOnly; got != test.originalBetsOnly {
+				t.Errorf("OriginalBetsOnly = %t, want %t", got, test.originalBetsOnly)
+			}
+		})
+	}
+
+	if _, err := ParseHoleCardRule("obo"); err == nil {
+		t.Error("expected an error for an invalid hole card rule")
+	}
+}
+
+// handWith returns a hand of the cards, where any two ten-value cards are a
+// pair if splitUnlikeTens is set.
+func handWith(splitUnlikeTens bool, ranks ...core.Rank) person.Hand {
+	hand := person.NewPlayerHand(splitUnlikeTens)
+	for _, rank := range ranks {
+		hand.AddCard(core.Card{Suit: core.Spades, Rank: rank})
+	}
+	return hand.Hand
+}
+
+func TestCanSurrenderEarly(t *testing.T) {
+	tests := []struct {
+		rule   SurrenderRule
+		upCard core.Rank
+		want   bool
+	}{
+		{rule: SurrenderNone, upCard: core.Ten, want: false},
+		{rule: SurrenderLate, upCard: core.Ten, want: false},
+		{rule: SurrenderLate, upCard: core.Ace, want: false},
+		{rule: SurrenderEarly, upCard: core.King, want: true},
+		{rule: SurrenderEarly, upCard: core.Ace, want: true},
+		{rule: SurrenderEarly, upCard: core.Nine, want: false},
+		{rule: SurrenderEarlyTen, upCard: core.Queen, want: true},
+		{rule: SurrenderEarlyTen, upCard: core.Ace, want: false},
+		{rule: SurrenderEarlyAce, upCard: core.Ten, want: false},
+		{rule: SurrenderEarlyAce, upCard: core.Ace, want: true},
+	}
+
+	for _, test := range tests {
+		options := testRulesOptions()
+		options.SurrenderRule = test.rule
+		upCard := core.Card{Suit: core.Clubs, Rank: test.upCard}
+
+		if got := NewRules(options).CanSurrenderEarly(upCard); got != test.want {
+			t.Errorf("%s: CanSurrenderEarly(%s) = %t, want %t", test.rule, upCard.ValueString(), got, test.want)
+		}
+	}
+}
+
+func TestGetActionsAllowedSurrender(t *testing.T) {
+	tests := []struct {
+		name                string
+		rule                SurrenderRule
+		surrenderAfterSplit bool
+		hand                person.Hand
+		numHands            int
+		want                bool
+	}{
+		{name: "none", rule: SurrenderNone, hand: handWith(false, core.Ten, core.Six), numHands: 1, want: false},
+		{name: "late", rule: SurrenderLate, hand: handWith(false, core.Ten, core.Six), numHands: 1, want: true},
+		{name: "early", rule: SurrenderEarly, hand: handWith(false, core.Ten, core.Six), numHands: 1, want: true},
+		{name: "three cards", rule: SurrenderLate, hand: handWith(false, core.Ten, core.Two, core.Four), numHands: 1, want: false},
+		{name: "after split", rule: SurrenderLate, hand: handWith(false, core.Eight, core.Eight), numHands: 2, want: false},
+		{name: "after split allowed", rule: SurrenderLate, surrenderAfterSplit: true, hand: handWith(false, core.Eight, core.Eight), numHands: 2, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testRulesOptions()
+			options.SurrenderRule = test.rule
+			options.SurrenderAfterSplit = test.surrenderAfterSplit
+
+			actionsAllowed, err := NewRules(options).GetActionsAllowed(test.hand, test.numHands, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := actionsAllowed[blackjack.Surrender]; got != test.want {
+				t.Errorf("surrender allowed = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCanSurrenderAfterDouble(t *testing.T) {
+	tests := []struct {
+		rule                 SurrenderRule
+		surrenderAfterDouble bool
+		want                 bool
+	}{
+		{rule: SurrenderNone, surrenderAfterDouble: true, want: false},
+		{rule: SurrenderLate, surrenderAfterDouble: false, want: false},
+		{rule: SurrenderLate, surrenderAfterDouble: true, want: true},
+	}
+
+	for _, test := range tests {
+		options := testRulesOptions()
+		options.SurrenderRule = test.rule
+		options.SurrenderAfterDouble = test.surrenderAfterDouble
+
+		if got := NewRules(options).CanSurrenderAfterDouble(); got != test.want {
+			t.Errorf("%s, surrenderAfterDouble %t: CanSurrenderAfterDouble() = %t, want %t", test.rule, test.surrenderAfterDouble, got, test.want)
+		}
+	}
+}
+
+func TestGetActionsAllowedSplit(t *testing.T) {
+	tests := []struct {
+		name               string
+		maxNumHands        int
+		maxNumHandsByRank  map[string]int
+		splitAfterSplitAce bool
+		splitUnlikeTens    bool
+		ranks              []core.Rank
+		numHands           int
+		splitAce           bool
+		want               bool
+	}{
+		{name: "pair", maxNumHands: 4, ranks: []core.Rank{core.Eight, core.Eight}, numHands: 1, want: true},
+		{name: "resplit", maxNumHands: 4, ranks: []core.Rank{core.Eight, core.Eight}, numHands: 3, want: true},
+		{name: "hand limit", maxNumHands: 4, ranks: []core.Rank{core.Eight, core.Eight}, numHands: 4, want: false},
+		{name: "no limit", maxNumHands: -1, ranks: []core.Rank{core.Eight, core.Eight}, numHands: 8, want: true},
+		{name: "not a pair", maxNumHands: 4, ranks: []core.Rank{core.Eight, core.Nine}, numHands: 1, want: false},
+		{name: "rank limit", maxNumHands: 4, maxNumHandsByRank: map[string]int{"8": 2}, ranks: []core.Rank{core.Eight, core.Eight}, numHands: 2, want: false},
+		{name: "other rank", maxNumHands: 4, maxNumHandsByRank: map[string]int{"A": 2}, ranks: []core.Rank{core.Eight, core.Eight}, numHands: 3, want: true},
+		{name: "rank above limit", maxNumHands: 2, maxNumHandsByRank: map[string]int{"8": 4}, ranks: []core.Rank{core.Eight, core.Eight}, numHands: 3, want: true},
+		{name: "aces", maxNumHands: 4, maxNumHandsByRank: map[string]int{"A": 2}, ranks: []core.Rank{core.Ace, core.Ace}, numHands: 1, want: true},
+		{name: "no resplit aces", maxNumHands: 4, ranks: []core.Rank{core.Ace, core.Ace}, numHands: 2, splitAce: true, want: false},
+		{name: "resplit aces", maxNumHands: 4, splitAfterSplitAce: true, ranks: []core.Rank{core.Ace, core.Ace}, numHands: 2, splitAce: true, want: true},
+		{name: "resplit aces limit", maxNumHands: 4, maxNumHandsByRank: map[string]int{"A": 2}, splitAfterSplitAce: true, ranks: []core.Rank{core.Ace, core.Ace}, numHands: 2, splitAce: true, want: false},
+		{name: "like tens", maxNumHands: 4, ranks: []core.Rank{core.King, core.King}, numHands: 1, want: true},
+		{name: "unlike tens", maxNumHands: 4, ranks: []core.Rank{core.King, core.Queen}, numHands: 1, want: false},
+		{name: "unlike tens allowed", maxNumHands: 4, splitUnlikeTens: true, ranks: []core.Rank{core.King, core.Queen}, numHands: 1, want: true},
+		{name: "unlike tens limit", maxNumHands: 4, maxNumHandsByRank: map[string]int{"10": 2}, splitUnlikeTens: true, ranks: []core.Rank{core.Ten, core.Jack}, numHands: 2, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testRulesOptions()
+			options.MaxNumHands = test.maxNumHands
+			options.MaxNumHandsByRank = test.maxNumHandsByRank
+			options.SplitAfterSplitAce = test.splitAfterSplitAce
+			options.SplitUnlikeTens = test.splitUnlikeTens
+
+			hand := handWith(test.splitUnlikeTens, test.ranks...)
+			actionsAllowed, err := NewRules(options).GetActionsAllowed(hand, test.numHands, test.splitAce)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := actionsAllowed[blackjack.Split]; got != test.want {
+				t.Errorf("split allowed = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsCharlie(t *testing.T) {
+	tests := []struct {
+		name         string
+		charlieCards int
+		ranks        []core.Rank
+		want         bool
+	}{
+		{name: "no charlie", charlieCards: 0, ranks: []core.Rank{core.Two, core.Two, core.Two, core.Two, core.Two, core.Two, core.Two}, want: false},
+		{name: "five cards", charlieCards: 5, ranks: []core.Rank{core.Two, core.Three, core.Two, core.Four, core.Ace}, want: true},
+		{name: "four cards", charlieCards: 5, ranks: []core.Rank{core.Two, core.Three, core.Two, core.Four}, want: false},
+		{name: "busted", charlieCards: 5, ranks: []core.Rank{core.Ten, core.Two, core.Two, core.Two, core.Ten}, want: false},
+		{name: "six of seven", charlieCards: 7, ranks: []core.Rank{core.Two, core.Two, core.Two, core.Two, core.Two, core.Two}, want: false},
+		{name: "seven", charlieCards: 7, ranks: []core.Rank{core.Two, core.Two, core.Two, core.Two, core.Two, core.Two, core.Ace}, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testRulesOptions()
+			options.CharlieCards = test.charlieCards
+
+			if got := NewRules(options).IsCharlie(handWith(false, test.ranks...)); got != test.want {
+				t.Errorf("IsCharlie() = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package simulation
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/jljl1337/blackjack-simulator/internal/result"
+)
+
+// tripInput is a trip to play with its own source of randomness.
+type tripInput struct {
+	tripId uint
+	seed   int64
+}
+
+// runTrips plays trips of sessions, where each session plays consecutive
+// shuffles until the session time, stop-loss or win goal is reached, and
+// reports the distribution of the results.
+func (s *Simulator) runTrips() error {
+	startTime := time.Now()
+	random := rand.New(rand.NewSource(s.seed))
+
+	inputChan := make(chan tripInput, s.numWorkers)
+	resultChan := make(chan result.TripResult, s.numWorkers)
+
+	for range s.numWorkers {
+		go func() {
+			for input := range inputChan {
+				resultChan <- s.playTrip(input)
+			}
+		}()
+	}
+
+	go func() {
+		for tripId := range s.numTrips {
+			inputChan <- tripInput{tripId: tripId, seed: random.Int63()}
+		}
+		close(inputChan)
+	}()
+
+	var summary result.TripSummary
+	var firstErr error
+	for range s.numTrips {
+		trip := <-resultChan
+		if trip.Error != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error in trip %d: %w", trip.TripId, trip.Error)
+			}
+			continue
+		}
+		summary.Add(trip)
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	log.Printf("Finished %d trips of %d sessions using %.3f seconds\n", summary.NumTrips, summary.NumSessions, time.Since(startTime).Seconds())
+
+	log.Printf("Session length: %g hours at %g rounds per hour\n", s.sessionHours, s.roundsPerHour)
+	if s.stopLoss > 0 {
+		log.Printf("Stop-loss: %d\n", s.stopLoss)
+	}
+	if s.winGoal > 0 {
+		log.Printf("Win goal: %d\n", s.winGoal)
+	}
+	if s.bankroll > 0 {
+		log.Printf("Trip bankroll: %d\n", s.bankroll)
+	}
+
+	logDistribution("Session", summary.SessionBalances())
+	log.Printf("Sessions ended by time: %.2f%%, win goal: %.2f%%, stop-loss: %.2f%%, bust: %.2f%%\n",
+		summary.OutcomeShare(result.SessionTime)*100,
+		summary.OutcomeShare(result.SessionWinGoal)*100,
+		summary.OutcomeShare(result.SessionStopLoss)*100,
+		summary.OutcomeShare(result.SessionBust)*100,
+	)
+	if s.winGoal > 0 {
+		log.Printf("Probability of reaching the win goal: %.4f%%\n", summary.OutcomeShare(result.SessionWinGoal)*100)
+	}
+
+	logDistribution("Trip", summary.TripBalances())
+	if s.bankroll > 0 {
+		log.Printf("Probability of busting the trip bankroll: %.4f%%\n", summary.BustShare()*100)
+	}
+
+	log.Printf("Simulation completed successfully\n")
+	return nil
+}
+
+// logDistribution logs the mean, standard deviation and percentiles of a
+// balance.
+func logDistribution(name string, distribution result.Distribution) {
+	log.Printf("%s balance: mean %.2f, standard deviation %.2f\n", name, distribution.Mean(), distribution.StandardDeviation())
+	log.Printf("%s balance percentiles: 5%%: %d, 25%%: %d, 50%%: %d, 75%%: %d, 95%%: %d\n",
+		name,
+		distribution.Percentile(0.05),
+		distribution.Percentile(0.25),
+		distribution.Percentile(0.5),
+		distribution.Percentile(0.75),
+		distribution.Percentile(0.95),
+	)
+}
+
+// playTrip plays the sessions of a trip until they are all played or the
+// trip bankroll is lost.
+func (s *Simulator) playTrip(input tripInput) result.TripResult {
+	random := rand.New(rand.NewSource(input.seed))
+	trip := result.TripResult{TripId: input.tripId}
+
+	for range s.sessionsPerTrip {
+		session, err := s.playSession(random, trip.Balance)
+		if err != nil {
+			trip.Error = err
+			return trip
+		}
+
+		trip.Sessions = append(trip.Sessions, session)
+		trip.Balance += session.Balance
+
+		if session.Outcome == result.SessionBust {
+			trip.Busted = true
+			break
+		}
+	}
+
+	return trip
+}
+
+// playSession plays consecutive shuffles with the same bankroll until the
+// session ends. The player leaves the table in the middle of a shuffle when
+// the session ends, and each shuffle starts with a new shoe.
+func (s *Simulator) playSession(random *rand.Rand, tripBalance int64) (result.SessionResult, error) {
+	maxNumRounds := int(math.Round(s.sessionHours * s.roundsPerHour))
+
+	var session result.SessionResult
+	stop := func(round result.RoundResult) bool {
+		// Rounds observed without betting also take time
+		session.NumRounds++
+		session.Balance += int64(round.Balance)
+
+		switch {
+		case s.bankroll > 0 && tripBalance+session.Balance <= -s.bankroll:
+			session.Outcome = result.SessionBust
+		case s.stopLoss > 0 && session.Balance <= -s.stopLoss:
+			session.Outcome = result.SessionStopLoss
+		case s.winGoal > 0 && session.Balance >= s.winGoal:
+			session.Outcome = result.SessionWinGoal
+		case session.NumRounds >= maxNumRounds:
+			session.Outcome = result.SessionTime
+		default:
+			return false
+		}
+		return true
+	}
+
+	for shuffleId := uint(0); session.Outcome == ""; shuffleId++ {
+		input := s.newShuffleInput(shuffleId, random)
+		input.Stop = stop
+
+		shuffleResult := PlayShuffle(input)
+		if shuffleResult.Error != nil {
+			return result.SessionResult{}, fmt.Errorf("error in shuffle %d: %w", shuffleId, shuffleResult.Error)
+		}
+	}
+
+	return session, nil
+}
@@ -2,9 +2,11 @@ package simulation
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/jljl1337/blackjack-simulator/internal/blackjack"
 	"github.com/jljl1337/blackjack-simulator/internal/core"
+	"github.com/jljl1337/blackjack-simulator/internal/counting"
 	"github.com/jljl1337/blackjack-simulator/internal/person"
 	"github.com/jljl1337/blackjack-simulator/internal/result"
 )
@@ -14,12 +16,35 @@ type ShuffleInput struct {
 	Player    person.Player
 	Dealer    person.Dealer
 	Shoe      core.Shoe
+	Counter   counting.Counter
 	Rules     Rules
+	// Stop, if set, ends the shuffle after a round for which it returns true,
+	// e.g. when a session reaches its stop-loss.
+	Stop func(round result.RoundResult) bool
 }
 
-func PlayShuffleWorker(inputChan <-chan ShuffleInput, resultChan chan<- result.ShuffleResult) {
-	for input := range inputChan {
-		resultChan <- PlayShuffle(input)
+// stops reports whether the shuffle ends early after the round.
+func (input ShuffleInput) stops(round result.RoundResult) bool {
+	return input.Stop != nil && input.Stop(round)
+}
+
+// PlayShuffleWorker plays the shuffles from inputChan until it is closed or
+// done is closed, and sends the results to resultChan.
+func PlayShuffleWorker(inputChan <-chan ShuffleInput, resultChan chan<- result.ShuffleResult, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case input, ok := <-inputChan:
+			if !ok {
+				return
+			}
+			select {
+			case resultChan <- PlayShuffle(input):
+			case <-done:
+				return
+			}
+		}
 	}
 }
 
@@ -31,168 +56,410 @@ func PlayShuffle(input ShuffleInput) result.ShuffleResult {
 	dealer := input.Dealer
 	shoe := input.Shoe
 	rules := input.Rules
+	counter := input.Counter
 
-	for {
-		if err := player.PlaceBet(); err != nil {
+	// dealExposed deals a card face up, where the player can count it
+	dealExposed := func() core.Card {
+		card := shoe.Deal()
+		counter.Observe(card)
+		return card
+	}
+
+	// shoeState is what the player knows about the unseen cards when
+	// deciding, which change as cards are dealt during the turn
+	shoeState := func() blackjack.ShoeState {
+		unseenCards := shoe.RankCounts()
+		if rules.DealerPeeks() {
+			// The dealer's hole card is still unseen by the player
+			unseenCards[dealer.GetHoleCard().Rank]++
+		}
+
+		return blackjack.ShoeState{
+			UnseenCards:  unseenCards,
+			RunningCount: counter.RunningCount(),
+			TrueCount:    counter.TrueCount(shoe.DecksRemaining()),
+		}
+	}
+
+	for firstRound := true; ; firstRound = false {
+		runningCount := counter.RunningCount()
+		trueCount := counter.TrueCount(shoe.DecksRemaining())
+
+		var excessAces *float64
+		if counter.HasAceSideCount() {
+			aces := counter.ExcessAces(shoe.DecksRemaining())
+			excessAces = &aces
+		}
+
+		if err := player.PlaceBet(trueCount, firstRound); err != nil {
 			return result.NewShuffleResultWithError(shuffleId, err)
 		}
 
-		dealer.DrawCard(shoe.Deal())
-		dealer.DrawCard(shoe.Deal())
-		player.DrawCard(shoe.Deal())
-		player.DrawCard(shoe.Deal())
+		dealer.DrawCard(dealExposed())
+		if rules.DealerPeeks() {
+			// The hole card is only counted once it is revealed
+			dealer.DrawCard(shoe.Deal())
+		}
+		player.DrawCard(dealExposed())
+		player.DrawCard(dealExposed())
 
 		playerHasBlackjack, err := player.CurrentHandIsBlackjack()
 		if err != nil {
 			return result.NewShuffleResultWithError(shuffleId, err)
 		}
 
-		dealerHasBlackjack := dealer.HasBlackjack()
+		// Early surrender is offered before the dealer checks for blackjack
+		playerSurrendered := false
+		if !playerHasBlackjack && rules.CanSurrenderEarly(dealer.GetUpCard()) {
+			currentHand, err := player.GetCurrentHand()
+			if err != nil {
+				return result.NewShuffleResultWithError(shuffleId, err)
+			}
+
+			actionsAllowed, err := rules.GetActionsAllowed(currentHand, player.GetNumHands(), player.SplitAce())
+			if err != nil {
+				return result.NewShuffleResultWithError(shuffleId, err)
+			}
+
+			action, deviation, err := decideAction(&player, dealer.GetUpCard(), shoeState(), actionsAllowed)
+			if err != nil {
+				return result.NewShuffleResultWithError(shuffleId, err)
+			}
+
+			// Any other action is decided again in the player's turn
+			if action == blackjack.Surrender {
+				if deviation != nil {
+					if err := player.RecordDeviation(*deviation); err != nil {
+						return result.NewShuffleResultWithError(shuffleId, err)
+					}
+				}
+				if err := player.RecordAction(blackjack.Surrender); err != nil {
+					return result.NewShuffleResultWithError(shuffleId, err)
+				}
+				if err := player.SurrenderCurrentHandEarly(); err != nil {
+					return result.NewShuffleResultWithError(shuffleId, err)
+				}
+				playerSurrendered = true
+			}
+		}
+
+		if !playerSurrendered && dealer.GetUpCard().Rank == core.Ace {
+			unseenCards := shoe.Remaining()
+			unseenTenValueCards := shoe.RemainingTenValue()
+			if rules.DealerPeeks() {
+				// The dealer's hole card is still unseen by the player
+				unseenCards++
+				if dealer.GetHoleCard().IsTenValue() {
+					unseenTenValueCards++
+				}
+			}
+
+			if err := player.OfferInsurance(unseenCards, unseenTenValueCards, counter.TrueCount(shoe.DecksRemaining())); err != nil {
+				return result.NewShuffleResultWithError(shuffleId, err)
+			}
+		}
+
+		// Without a hole card, the dealer can only have blackjack after the
+		// player has acted
+		dealerHasBlackjack := rules.DealerPeeks() && dealer.HasBlackjack()
+
+		if rules.DealerPeeks() {
+			if err := player.SettleInsurance(dealerHasBlackjack); err != nil {
+				return result.NewShuffleResultWithError(shuffleId, err)
+			}
+		}
 
 		if dealerHasBlackjack {
 			// Dealer has blackjack, check if player also has blackjack
-			if !playerHasBlackjack {
+			if !playerHasBlackjack && !playerSurrendered {
 				// Dealer wins
-				player.LoseCurrentHand(1)
+				player.LoseCurrentHand(blackjack.EvenMoney)
 			}
 			// Player also has blackjack, it's a push
+			counter.Observe(dealer.GetHoleCard())
+
 			// TODO: extract end round logic to a function?
 			roundResults = append(roundResults, result.NewRoundResult(
 				dealer.GetHand(),
 				player.GetHands(),
+				player.GetInitialBet(),
+				!player.IsSeated(),
+				runningCount,
+				trueCount,
+				excessAces,
 			))
 
 			player.EndRound()
 			dealer.EndRound()
 
-			if shoe.NeedsShuffle() {
+			if shoe.NeedsShuffle() || input.stops(roundResults[len(roundResults)-1]) {
 				// Finish this shuffle and start a new one
 				return result.NewShuffleResult(shuffleId, roundResults)
 			}
 			continue
 		}
 
-		// Player's turn
-		for {
-			actions, err := player.GetActions(dealer.GetUpCard())
-			if err != nil {
+		// Player's turn, skipped if the player surrendered early
+		if !playerSurrendered {
+			if err := playPlayerHands(&player, dealer.GetUpCard(), rules, dealExposed, shoeState, blackjack.NA); err != nil {
 				return result.NewShuffleResultWithError(shuffleId, err)
 			}
+		}
 
-			currentHandSize, err := player.GetCurrentHandSize()
-			if err != nil {
-				return result.NewShuffleResultWithError(shuffleId, err)
-			}
+		// Dealer's turn
+		if rules.DealerPeeks() {
+			counter.Observe(dealer.GetHoleCard())
+		}
+		if err := playDealerHand(&player, &dealer, rules, dealExposed, dealerHasBlackjack); err != nil {
+			return result.NewShuffleResultWithError(shuffleId, err)
+		}
 
-			actionsAllowed, err := rules.GetActionsAllowed(currentHandSize, player.GetNumHands(), player.SplitAce())
-			if err != nil {
-				return result.NewShuffleResultWithError(shuffleId, err)
-			}
+		roundResults = append(roundResults, result.NewRoundResult(
+			dealer.GetHand(),
+			player.GetHands(),
+			player.GetInitialBet(),
+			!player.IsSeated(),
+			runningCount,
+			trueCount,
+			excessAces,
+		))
 
-			selectedAction := blackjack.NA
+		player.EndRound()
+		dealer.EndRound()
 
-			currentHandIsBlackjack, err := player.CurrentHandIsBlackjack()
+		if shoe.NeedsShuffle() || input.stops(roundResults[len(roundResults)-1]) {
+			// Finish this shuffle and start a new one
+			return result.NewShuffleResult(shuffleId, roundResults)
+		}
+	}
+}
+
+// playPlayerHands plays the player's hands until each of them is settled or
+// stands. Unless firstAction is NA, it is taken as the first decision instead
+// of the one from the strategy.
+func playPlayerHands(player *person.Player, dealerUpCard core.Card, rules Rules, dealExposed func() core.Card, shoeState func() blackjack.ShoeState, firstAction blackjack.Action) error {
+	for {
+		currentHand, err := player.GetCurrentHand()
+		if err != nil {
+			return err
+		}
+
+		actionsAllowed, err := rules.GetActionsAllowed(currentHand, player.GetNumHands(), player.SplitAce())
+		if err != nil {
+			return err
+		}
+
+		selectedAction := blackjack.NA
+
+		currentHandIsBlackjack, err := player.CurrentHandIsNatural()
+		if err != nil {
+			return err
+		}
+
+		if rules.BlackjackAfterSplit() {
+			// Two-card 21s formed by splitting are treated as naturals
+			currentHandIsBlackjack, err = player.CurrentHandIsBlackjack()
 			if err != nil {
-				return result.NewShuffleResultWithError(shuffleId, err)
+				return err
 			}
+		}
 
-			if currentHandIsBlackjack {
-				// Skip selecting action if the player has blackjack
-				selectedAction = blackjack.Blackjack
-			} else {
-				for _, action := range actions {
-					if actionsAllowed[action] {
-						selectedAction = action
-						break
-					}
+		if currentHandIsBlackjack {
+			// Skip selecting action if the player has blackjack
+			selectedAction = blackjack.Blackjack
+		} else if firstAction != blackjack.NA {
+			if !actionsAllowed[firstAction] {
+				return fmt.Errorf("action %s is not allowed", firstAction)
+			}
+			selectedAction = firstAction
+			firstAction = blackjack.NA
+		} else {
+			var deviation *blackjack.Index
+			selectedAction, deviation, err = decideAction(player, dealerUpCard, shoeState(), actionsAllowed)
+			if err != nil {
+				return err
+			}
+			if deviation != nil {
+				if err := player.RecordDeviation(*deviation); err != nil {
+					return err
 				}
 			}
+		}
 
-			if selectedAction == blackjack.NA {
-				return result.NewShuffleResultWithError(shuffleId, errors.New("no valid action selected"))
-			}
+		if selectedAction == blackjack.NA {
+			return errors.New("no valid action selected")
+		}
 
-			if selectedAction != blackjack.Blackjack {
-				if err := player.RecordAction(selectedAction); err != nil {
-					return result.NewShuffleResultWithError(shuffleId, err)
-				}
+		if selectedAction != blackjack.Blackjack {
+			if err := player.RecordAction(selectedAction); err != nil {
+				return err
 			}
+		}
 
-			playerLoseRatio := 0.0
+		var playerLoss blackjack.Payout
 
-			switch selectedAction {
-			case blackjack.Blackjack:
-				// If the dealer has blackjack, the player already pushed
-			case blackjack.Hit:
-				player.Hit(shoe.Deal())
-				isBusted, err := player.CurrentHandIsBusted()
-				if err != nil {
-					return result.NewShuffleResultWithError(shuffleId, err)
+		switch selectedAction {
+		case blackjack.Blackjack:
+			// If the dealer has blackjack, the player already pushed
+		case blackjack.Hit:
+			player.Hit(dealExposed())
+			isBusted, err := player.CurrentHandIsBusted()
+			if err != nil {
+				return err
+			}
+			if isBusted {
+				// Player busts, dealer wins
+				playerLoss = blackjack.EvenMoney // Player loses the full bet
+			} else if isCharlie, err := currentHandIsCharlie(*player, rules); err != nil {
+				return err
+			} else if isCharlie {
+				// Player reaches the Charlie number of cards and wins
+				if err := player.WinCurrentHandByCharlie(rules.CharliePayout()); err != nil {
+					return err
 				}
-				if isBusted {
-					// Player busts, dealer wins
-					playerLoseRatio = 1.0 // Player loses the full bet
-				} else {
-					// Player hits, continue to next action
-					continue
+			} else {
+				// Player hits, continue to next action
+				continue
+			}
+		case blackjack.Stand:
+		case blackjack.Double:
+			if err := player.DoubleDown(dealExposed()); err != nil {
+				return err
+			}
+			isBusted, err := player.CurrentHandIsBusted()
+			if err != nil {
+				return err
+			}
+			if isBusted {
+				// Player busts, dealer wins
+				playerLoss = blackjack.EvenMoney // Player loses the full bet
+			} else if isCharlie, err := currentHandIsCharlie(*player, rules); err != nil {
+				return err
+			} else if isCharlie {
+				// Doubling on any number of cards can also reach a Charlie
+				if err := player.WinCurrentHandByCharlie(rules.CharliePayout()); err != nil {
+					return err
 				}
-			case blackjack.Stand:
-			case blackjack.Double:
-				if err := player.DoubleDown(shoe.Deal()); err != nil {
-					return result.NewShuffleResultWithError(shuffleId, err)
+			} else if rules.CanSurrenderAfterDouble() {
+				// Double-down rescue, the player may only stand or forfeit
+				// the doubled hand for the original bet
+				rescueAllowed := map[blackjack.Action]bool{
+					blackjack.Stand:     true,
+					blackjack.Surrender: true,
 				}
-				isBusted, err := player.CurrentHandIsBusted()
+
+				action, deviation, err := decideAction(player, dealerUpCard, shoeState(), rescueAllowed)
 				if err != nil {
-					return result.NewShuffleResultWithError(shuffleId, err)
+					return err
 				}
-				if isBusted {
-					// Player busts, dealer wins
-					playerLoseRatio = 1.0 // Player loses the full bet
-				}
-			case blackjack.Split:
-				newCards := []core.Card{shoe.Deal(), shoe.Deal()}
-				if err := player.Split(newCards); err != nil {
-					return result.NewShuffleResultWithError(shuffleId, err)
+
+				if action == blackjack.Surrender {
+					if deviation != nil {
+						if err := player.RecordDeviation(*deviation); err != nil {
+							return err
+						}
+					}
+					if err := player.RecordAction(blackjack.Surrender); err != nil {
+						return err
+					}
+					playerLoss = blackjack.HalfBet // Half of the doubled bet
 				}
-				// After splitting, player plays the new hand
-				continue
-			case blackjack.Surrender:
-				playerLoseRatio = 0.5 // Player surrenders, loses half the bet
 			}
-
-			// Should only reach here if the current hand is ended
-			if playerLoseRatio > 0 {
-				// Player loses the hand, dealer wins
-				player.LoseCurrentHand(playerLoseRatio)
+		case blackjack.Split:
+			newCards := []core.Card{dealExposed(), dealExposed()}
+			if err := player.Split(newCards); err != nil {
+				return err
 			}
+			// After splitting, player plays the new hand
+			continue
+		case blackjack.Surrender:
+			playerLoss = blackjack.HalfBet // Player surrenders, loses half the bet
+		}
 
-			if !player.HasNextHand() {
-				break
-			}
+		// Should only reach here if the current hand is ended
+		if !playerLoss.IsZero() {
+			// Player loses the hand, dealer wins
+			player.LoseCurrentHand(playerLoss)
+		}
 
-			// Player has more hands to play, continue to the next hand
-			player.NextHand()
+		if !player.HasNextHand() {
+			return nil
 		}
 
-		// Dealer's turn
-		for dealer.NeedsToHit() {
-			dealer.DrawCard(shoe.Deal())
+		// Player has more hands to play, continue to the next hand
+		player.NextHand()
+	}
+}
+
+// playDealerHand plays the dealer's hand and settles the player's hands. With
+// a hole card, dealerHasBlackjack is already known from the peek, otherwise
+// the dealer's second card is dealt first.
+func playDealerHand(player *person.Player, dealer *person.Dealer, rules Rules, dealExposed func() core.Card, dealerHasBlackjack bool) error {
+	if !rules.DealerPeeks() {
+		// Deal the dealer's second card now that the player has acted
+		dealer.DrawCard(dealExposed())
+		dealerHasBlackjack = dealer.HasBlackjack()
+
+		if err := player.SettleInsurance(dealerHasBlackjack); err != nil {
+			return err
 		}
+	}
+
+	for dealer.NeedsToHit(rules.DealerHitsSoft17()) {
+		dealer.DrawCard(dealExposed())
+	}
 
+	if dealerHasBlackjack {
+		player.LoseToDealerBlackjack(rules.SettlementRules())
+	} else {
 		dealerValue := dealer.GetHandValue()
-		player.CalculateHandBet(dealerValue)
+		player.CalculateHandBet(dealerValue, rules.SettlementRules())
+	}
 
-		roundResults = append(roundResults, result.NewRoundResult(
-			dealer.GetHand(),
-			player.GetHands(),
-		))
+	return nil
+}
 
-		player.EndRound()
-		dealer.EndRound()
+// currentHandIsCharlie checks if the player's current hand has reached the
+// Charlie number of cards without busting.
+func currentHandIsCharlie(player person.Player, rules Rules) (bool, error) {
+	currentHand, err := player.GetCurrentHand()
+	if err != nil {
+		return false, err
+	}
 
-		if shoe.NeedsShuffle() {
-			// Finish this shuffle and start a new one
-			return result.NewShuffleResult(shuffleId, roundResults)
+	return rules.IsCharlie(currentHand), nil
+}
+
+// decideAction selects the action for the player's current hand. An index
+// that applies at the true count overrides the basic strategy, and is
+// returned only if it changes the action.
+func decideAction(player *person.Player, dealerUpCard core.Card, shoeState blackjack.ShoeState, actionsAllowed map[blackjack.Action]bool) (blackjack.Action, *blackjack.Index, error) {
+	action, err := player.Decide(blackjack.DecisionContext{
+		DealerUpCard:   dealerUpCard,
+		ActionsAllowed: actionsAllowed,
+		ShoeState:      shoeState,
+	})
+	if err != nil {
+		return blackjack.NA, nil, err
+	}
+
+	// The hard total indexes do not apply to a pair the strategy splits
+	indexes, err := player.GetIndexes(dealerUpCard, shoeState.TrueCount, action == blackjack.Split)
+	if err != nil {
+		return blackjack.NA, nil, err
+	}
+
+	for _, index := range indexes {
+		deviationAction := blackjack.SelectAction(index.Actions, actionsAllowed)
+		if deviationAction == blackjack.NA {
+			// Try the next index, e.g. when surrender is not allowed
+			continue
 		}
+		if deviationAction == action {
+			return action, nil, nil
+		}
+		return deviationAction, &index, nil
 	}
+
+	return action, nil, nil
 }
@@ -0,0 +1,650 @@
+package simulation
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jljl1337/blackjack-simulator/internal/betting"
+	"github.com/jljl1337/blackjack-simulator/internal/blackjack"
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+	"github.com/jljl1337/blackjack-simulator/internal/counting"
+	"github.com/jljl1337/blackjack-simulator/internal/person"
+	"github.com/jljl1337/blackjack-simulator/internal/result"
+)
+
+func TestDecideActionSplitsPairsDespiteTotalIndexes(t *testing.T) {
+	strategy, err := blackjack.NewBasicStrategyS17()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexTable, err := blackjack.NewIndexTable([]string{"illustrious18", "fab4"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actionsAllowed := map[blackjack.Action]bool{
+		blackjack.Hit:       true,
+		blackjack.Stand:     true,
+		blackjack.Double:    true,
+		blackjack.Split:     true,
+		blackjack.Surrender: true,
+	}
+
+	tests := []struct {
+		name      string
+		pair      core.Rank
+		upCard    core.Rank
+		trueCount float64
+	}{
+		// H16,10,>=0,US
+		{name: "8,8 vs 10", pair: core.Eight, upCard: core.Ten, trueCount: 0},
+		// H16,9,>=5,US
+		{name: "8,8 vs 9", pair: core.Eight, upCard: core.Nine, trueCount: 5},
+		// H12,4,<0,H
+		{name: "6,6 vs 4", pair: core.Six, upCard: core.Four, trueCount: -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			player := person.NewPlayer(strategy, blackjack.NeverInsurance{}, nil, indexTable, nil, false)
+			for _, suit := range []core.Suit{core.Spades, core.Hearts} {
+				if err := player.DrawCard(core.Card{Suit: suit, Rank: test.pair}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			dealerUpCard := core.Card{Suit: core.Clubs, Rank: test.upCard}
+			shoeState := blackjack.ShoeState{TrueCount: test.trueCount}
+
+			action, deviation, err := decideAction(player, dealerUpCard, shoeState, actionsAllowed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action != blackjack.Split {
+				t.Errorf("got action %s, want %s", action, blackjack.Split)
+			}
+			if deviation != nil {
+				t.Errorf("got deviation %s, want none", deviation.Name())
+			}
+		})
+	}
+}
+
+func TestPlayShuffleWorkerStops(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(inputChan chan ShuffleInput, done chan struct{})
+	}{
+		{name: "input closed", close: func(inputChan chan ShuffleInput, done chan struct{}) { close(inputChan) }},
+		{name: "done closed", close: func(inputChan chan ShuffleInput, done chan struct{}) { close(done) }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inputChan := make(chan ShuffleInput)
+			resultChan := make(chan result.ShuffleResult)
+			done := make(chan struct{})
+
+			exited := make(chan struct{})
+			go func() {
+				PlayShuffleWorker(inputChan, resultChan, done)
+				close(exited)
+			}()
+
+			test.close(inputChan, done)
+			select {
+			case <-exited:
+			case <-time.After(time.Second):
+				t.Fatal("worker did not exit")
+			}
+		})
+	}
+}
+
+// fixedStrategy plays the first allowed action in order of preference, and
+// stands otherwise.
+type fixedStrategy []blackjack.Action
+
+func (fs fixedStrategy) Decide(decision blackjack.DecisionContext) (blackjack.Action, error) {
+	if action := blackjack.SelectAction(fs, decision.ActionsAllowed); action != blackjack.NA {
+		return action, nil
+	}
+	return blackjack.Stand, nil
+}
+
+// playRound plays a single round with a flat bet of 10, dealing the cards in
+// order: the dealer's up card, the hole card if the dealer peeks, the
+// player's two cards, the player's draws and then the dealer's.
+func playRound(t *testing.T, options RulesOptions, actions []blackjack.Action, ranks ...core.Rank) result.RoundResult {
+	t.Helper()
+	return playRoundWithStrategy(t, options, fixedStrategy(actions), ranks...)
+}
+
+// playRoundWithStrategy is playRound with the player playing the strategy.
+func playRoundWithStrategy(t *testing.T, options RulesOptions, strategy blackjack.Strategy, ranks ...core.Rank) result.RoundResult {
+	t.Helper()
+
+	bettingPolicy, err := betting.NewFlatBet(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cards := make([]core.Card, 0, len(ranks))
+	for i, rank := range ranks {
+		// Alternate suits so that no blackjack is suited
+		suit := core.Spades
+		if i%2 == 1 {
+			suit = core.Hearts
+		}
+		cards = append(cards, core.Card{Suit: suit, Rank: rank})
+	}
+
+	rules := NewRules(options)
+	input := ShuffleInput{
+		Player:  *person.NewPlayer(strategy, blackjack.NeverInsurance{}, bettingPolicy, nil, nil, rules.SplitUnlikeTens()),
+		Dealer:  *person.NewDealer(),
+		Shoe:    *core.NewShoeFromCards(cards, 1, 0),
+		Counter: *counting.NewCounter(counting.System{}, 1),
+		Rules:   rules,
+	}
+
+	shuffleResult := PlayShuffle(input)
+	if shuffleResult.Error != nil {
+		t.Fatal(shuffleResult.Error)
+	}
+	if shuffleResult.NumRounds != 1 {
+		t.Fatalf("played %d rounds, want 1", shuffleResult.NumRounds)
+	}
+	return shuffleResult.RoundResults[0]
+}
+
+func TestPlayShuffleHoleCard(t *testing.T) {
+	double := []blackjack.Action{blackjack.Double}
+	split := []blackjack.Action{blackjack.Split}
+
+	tests := []struct {
+		name     string
+		holeCard HoleCardRule
+		actions  []blackjack.Action
+		ranks    []core.Rank
+		want     int
+	}{
+		{
+			name:     "peek before doubling",
+			holeCard: HoleCardPeek,
+			actions:  double,
+			ranks:    []core.Rank{core.Ten, core.Ace, core.Five, core.Six},
+			want:     -10,
+		},
+		{
+			name:     "enhc doubled",
+			holeCard: NoHoleCard,
+			actions:  double,
+			ranks:    []core.Rank{core.Ten, core.Five, core.Six, core.Nine, core.Ace},
+			want:     -20,
+		},
+		{
+			name:     "obo doubled",
+			holeCard: NoHoleCardOBO,
+			actions:  double,
+			ranks:    []core.Rank{core.Ten, core.Five, core.Six, core.Nine, core.Ace},
+			want:     -10,
+		},
+		{
+			name:     "enhc split",
+			holeCard: NoHoleCard,
+			actions:  split,
+			ranks:    []core.Rank{core.Ten, core.Eight, core.Eight, core.Ten, core.Ten, core.Ace},
+			want:     -20,
+		},
+		{
+			name:     "obo split",
+			holeCard: NoHoleCardOBO,
+			actions:  split,
+			ranks:    []core.Rank{core.Ten, core.Eight, core.Eight, core.Ten, core.Ten, core.Ace},
+			want:     -10,
+		},
+		{
+			name:     "enhc natural pushes",
+			holeCard: NoHoleCard,
+			ranks:    []core.Rank{core.Ten, core.Ace, core.King, core.Ace},
+			want:     0,
+		},
+		{
+			name:     "enhc no dealer blackjack",
+			holeCard: NoHoleCard,
+			actions:  double,
+			ranks:    []core.Rank{core.Ten, core.Five, core.Six, core.Nine, core.Seven},
+			want:     20,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testRulesOptions()
+			options.HoleCardRule = test.holeCard
+
+			if round := playRound(t, options, test.actions, test.ranks...); round.Balance != test.want {
+				t.Errorf("got balance %d, want %d", round.Balance, test.want)
+			}
+		})
+	}
+}
+
+func TestPlayShuffleSurrender(t *testing.T) {
+	surrender := []blackjack.Action{blackjack.Surrender}
+
+	tests := []struct {
+		name      string
+		holeCard  HoleCardRule
+		surrender SurrenderRule
+		actions   []blackjack.Action
+		ranks     []core.Rank
+		want      int
+	}{
+		{
+			name:      "peek late",
+			holeCard:  HoleCardPeek,
+			surrender: SurrenderLate,
+			actions:   surrender,
+			ranks:     []core.Rank{core.Ten, core.Seven, core.Ten, core.Six},
+			want:      -5,
+		},
+		{
+			// The dealer peeks before the player may surrender late
+			name:      "peek late dealer blackjack",
+			holeCard:  HoleCardPeek,
+			surrender: SurrenderLate,
+			actions:   surrender,
+			ranks:     []core.Rank{core.Ten, core.Ace, core.Ten, core.Six},
+			want:      -10,
+		},
+		{
+			name:      "peek early dealer blackjack",
+			holeCard:  HoleCardPeek,
+			surrender: SurrenderEarly,
+			actions:   surrender,
+			ranks:     []core.Rank{core.Ace, core.King, core.Ten, core.Six},
+			want:      -5,
+		},
+		{
+			name:      "peek early ace only against ten",
+			holeCard:  HoleCardPeek,
+			surrender: SurrenderEarlyAce,
+			actions:   surrender,
+			ranks:     []core.Rank{core.Ten, core.Ace, core.Ten, core.Six},
+			want:      -10,
+		},
+		{
+			name:      "enhc late",
+			holeCard:  NoHoleCard,
+			surrender: SurrenderLate,
+			actions:   surrender,
+			ranks:     []core.Rank{core.Ten, core.Ten, core.Six, core.Seven},
+			want:      -5,
+		},
+		{
+			// Late surrender is only offered if the dealer has no blackjack
+			name:      "enhc late dealer blackjack",
+			holeCard:  NoHoleCard,
+			surrender: SurrenderLate,
+			actions:   surrender,
+			ranks:     []core.Rank{core.Ten, core.Ten, core.Six, core.Ace},
+			want:      -10,
+		},
+		{
+			name:      "obo late dealer blackjack",
+			holeCard:  NoHoleCardOBO,
+			surrender: SurrenderLate,
+			actions:   surrender,
+			ranks:     []core.Rank{core.Ten, core.Ten, core.Six, core.Ace},
+			want:      -10,
+		},
+		{
+			name:      "enhc early dealer blackjack",
+			holeCard:  NoHoleCard,
+			surrender: SurrenderEarlyTen,
+			actions:   surrender,
+			ranks:     []core.Rank{core.Ten, core.Ten, core.Six, core.Ace},
+			want:      -5,
+		},
+		{
+			name:      "obo early dealer blackjack",
+			holeCard:  NoHoleCardOBO,
+			surrender: SurrenderEarly,
+			actions:   surrender,
+			ranks:     []core.Rank{core.Ten, core.Ten, core.Six, core.Ace},
+			want:      -5,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testRulesOptions()
+			options.HoleCardRule = test.holeCard
+			options.SurrenderRule = test.surrender
+
+			if round := playRound(t, options, test.actions, test.ranks...); round.Balance != test.want {
+				t.Errorf("got balance %d, want %d", round.Balance, test.want)
+			}
+		})
+	}
+}
+
+func TestPlayShuffleResplit(t *testing.T) {
+	split := []blackjack.Action{blackjack.Split}
+
+	tests := []struct {
+		name               string
+		maxNumHandsByRank  map[string]int
+		splitAfterSplitAce bool
+		splitUnlikeTens    bool
+		ranks              []core.Rank
+		wantNumHands       int
+		want               int
+	}{
+		{
+			name:         "resplit",
+			ranks:        []core.Rank{core.Ten, core.Seven, core.Eight, core.Eight, core.Eight, core.Ten, core.Ten, core.Ten},
+			wantNumHands: 3,
+			want:         30,
+		},
+		{
+			// 8-8 is played as a hard 16 instead
+			name:              "rank limit",
+			maxNumHandsByRank: map[string]int{"8": 2},
+			ranks:             []core.Rank{core.Ten, core.Seven, core.Eight, core.Eight, core.Eight, core.Ten},
+			wantNumHands:      2,
+			want:              0,
+		},
+		{
+			// A-A is played as a soft 12 without hitting
+			name:         "no resplit aces",
+			ranks:        []core.Rank{core.Ten, core.Seven, core.Ace, core.Ace, core.Ace, core.Nine},
+			wantNumHands: 2,
+			want:         0,
+		},
+		{
+			name:               "resplit aces",
+			splitAfterSplitAce: true,
+			ranks:              []core.Rank{core.Ten, core.Seven, core.Ace, core.Ace, core.Ace, core.Nine, core.Nine, core.Nine},
+			wantNumHands:       3,
+			want:               30,
+		},
+		{
+			name:         "unlike tens",
+			ranks:        []core.Rank{core.Ten, core.Seven, core.King, core.Queen},
+			wantNumHands: 1,
+			want:         10,
+		},
+		{
+			name:            "unlike tens split",
+			splitUnlikeTens: true,
+			ranks:           []core.Rank{core.Ten, core.Seven, core.King, core.Queen, core.Nine, core.Nine},
+			wantNumHands:    2,
+			want:            20,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testRulesOptions()
+			options.MaxNumHandsByRank = test.maxNumHandsByRank
+			options.SplitAfterSplitAce = test.splitAfterSplitAce
+			options.SplitUnlikeTens = test.splitUnlikeTens
+
+			round := playRound(t, options, split, test.ranks...)
+			if round.NumHands != test.wantNumHands {
+				t.Errorf("got %d hands, want %d", round.NumHands, test.wantNumHands)
+			}
+			if round.Balance != test.want {
+				t.Errorf("got balance %d, want %d", round.Balance, test.want)
+			}
+		})
+	}
+}
+
+func TestPlayShuffleCharlie(t *testing.T) {
+	hit := []blackjack.Action{blackjack.Hit}
+
+	tests := []struct {
+		name          string
+		holeCard      HoleCardRule
+		charliePayout blackjack.Payout
+		ranks         []core.Rank
+		want          int
+	}{
+		{
+			name:          "charlie",
+			holeCard:      HoleCardPeek,
+			charliePayout: blackjack.EvenMoney,
+			ranks:         []core.Rank{core.Ten, core.Seven, core.Two, core.Two, core.Two, core.Two, core.Two},
+			want:          10,
+		},
+		{
+			name:          "charlie pays 2:1",
+			holeCard:      HoleCardPeek,
+			charliePayout: blackjack.TwoToOne,
+			ranks:         []core.Rank{core.Ten, core.Seven, core.Two, core.Two, core.Two, core.Two, core.Two},
+			want:          20,
+		},
+		{
+			name:          "busted on the fifth card",
+			holeCard:      HoleCardPeek,
+			charliePayout: blackjack.EvenMoney,
+			ranks:         []core.Rank{core.Ten, core.Seven, core.Ten, core.Two, core.Two, core.Two, core.Ten},
+			want:          -10,
+		},
+		{
+			// The Charlie is settled before the dealer turns over a blackjack
+			name:          "enhc dealer blackjack",
+			holeCard:      NoHoleCard,
+			charliePayout: blackjack.EvenMoney,
+			ranks:         []core.Rank{core.Ten, core.Two, core.Two, core.Two, core.Two, core.Two, core.Ace},
+			want:          10,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testRulesOptions()
+			options.HoleCardRule = test.holeCard
+			options.CharlieCards = 5
+			options.CharliePayout = test.charliePayout
+
+			if round := playRound(t, options, hit, test.ranks...); round.Balance != test.want {
+				t.Errorf("got balance %d, want %d", round.Balance, test.want)
+			}
+		})
+	}
+}
+
+func TestPlayShuffleDealerPush22(t *testing.T) {
+	tests := []struct {
+		name                 string
+		dealerPush22         bool
+		naturalBeatsDealer22 bool
+		actions              []blackjack.Action
+		ranks                []core.Rank
+		want                 int
+	}{
+		{
+			name:         "push",
+			dealerPush22: true,
+			ranks:        []core.Rank{core.Ten, core.Six, core.Ten, core.Nine, core.Six},
+			want:         0,
+		},
+		{
+			name:  "no push",
+			ranks: []core.Rank{core.Ten, core.Six, core.Ten, core.Nine, core.Six},
+			want:  10,
+		},
+		{
+			name:         "dealer 23",
+			dealerPush22: true,
+			ranks:        []core.Rank{core.Ten, core.Six, core.Ten, core.Nine, core.Seven},
+			want:         10,
+		},
+		{
+			name:         "player busts",
+			dealerPush22: true,
+			actions:      []blackjack.Action{blackjack.Hit},
+			ranks:        []core.Rank{core.Ten, core.Six, core.Ten, core.Six, core.Ten, core.Six},
+			want:         -10,
+		},
+		{
+			name:         "natural pushes",
+			dealerPush22: true,
+			ranks:        []core.Rank{core.Ten, core.Six, core.Ace, core.King, core.Six},
+			want:         0,
+		},
+		{
+			name:                 "natural beats 22",
+			dealerPush22:         true,
+			naturalBeatsDealer22: true,
+			ranks:                []core.Rank{core.Ten, core.Six, core.Ace, core.King, core.Six},
+			want:                 15,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testRulesOptions()
+			options.DealerPush22 = test.dealerPush22
+			options.NaturalBeatsDealer22 = test.naturalBeatsDealer22
+
+			if round := playRound(t, options, test.actions, test.ranks...); round.Balance != test.want {
+				t.Errorf("got balance %d, want %d", round.Balance, test.want)
+			}
+		})
+	}
+}
+
+func TestPlayShuffleDealerSoft17(t *testing.T) {
+	tests := []struct {
+		name             string
+		dealerHitsSoft17 bool
+		ranks            []core.Rank
+		want             int
+	}{
+		{name: "S17 stands on A-6", ranks: []core.Rank{core.Six, core.Ace, core.Ten, core.Eight, core.Four}, want: 10},
+		{name: "H17 hits A-6", dealerHitsSoft17: true, ranks: []core.Rank{core.Six, core.Ace, core.Ten, core.Eight, core.Four}, want: -10},
+		{name: "H17 hits A-A-5", dealerHitsSoft17: true, ranks: []core.Rank{core.Ace, core.Ace, core.Ten, core.Eight, core.Five, core.Three}, want: -10},
+		{name: "H17 stands on hard 17", dealerHitsSoft17: true, ranks: []core.Rank{core.Six, core.Ace, core.Ten, core.Eight, core.Ten, core.Four}, want: 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testRulesOptions()
+			options.DealerHitsSoft17 = test.dealerHitsSoft17
+
+			if round := playRound(t, options, nil, test.ranks...); round.Balance != test.want {
+				t.Errorf("got balance %d, want %d", round.Balance, test.want)
+			}
+		})
+	}
+}
+
+// decisionRecord is what a decision context held when it was decided, as
+// the hand it refers to changes afterwards.
+type decisionRecord struct {
+	playerHand  string
+	numHands    int
+	fromSplit   bool
+	splitAce    bool
+	unseenCards [core.King + 1]int
+}
+
+// recordingStrategy plays its strategy and records the decisions asked of it.
+type recordingStrategy struct {
+	strategy  blackjack.Strategy
+	decisions []decisionRecord
+}
+
+func (rs *recordingStrategy) Decide(decision blackjack.DecisionContext) (blackjack.Action, error) {
+	playerHand := decision.PlayerHand.ValueString()
+	if pairString, err := decision.PlayerHand.PairString(); err == nil {
+		playerHand = pairString
+	}
+
+	rs.decisions = append(rs.decisions, decisionRecord{
+		playerHand:  playerHand,
+		numHands:    decision.NumHands,
+		fromSplit:   decision.FromSplit,
+		splitAce:    decision.SplitAce,
+		unseenCards: decision.UnseenCards,
+	})
+	return rs.strategy.Decide(decision)
+}
+
+func TestPlayShuffleDecisionContext(t *testing.T) {
+	tests := []struct {
+		name               string
+		splitAfterSplitAce bool
+		ranks              []core.Rank
+		want               []decisionRecord
+	}{
+		{
+			name:  "split",
+			ranks: []core.Rank{core.Ten, core.Seven, core.Eight, core.Eight, core.Three, core.Ten},
+			want: []decisionRecord{
+				{playerHand: "P8", numHands: 1},
+				{playerHand: "H11", numHands: 2, fromSplit: true},
+				{playerHand: "H18", numHands: 2, fromSplit: true},
+			},
+		},
+		{
+			// Split aces are decided only to resplit them
+			name:               "split aces",
+			splitAfterSplitAce: true,
+			ranks:              []core.Rank{core.Ten, core.Seven, core.Ace, core.Ace, core.Nine, core.Ace, core.Nine, core.Nine},
+			want: []decisionRecord{
+				{playerHand: "PA", numHands: 1},
+				{playerHand: "S20", numHands: 2, fromSplit: true, splitAce: true},
+				{playerHand: "PA", numHands: 2, fromSplit: true, splitAce: true},
+				{playerHand: "S20", numHands: 3, fromSplit: true, splitAce: true},
+				{playerHand: "S20", numHands: 3, fromSplit: true, splitAce: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := testRulesOptions()
+			options.SplitAfterSplitAce = test.splitAfterSplitAce
+			strategy := &recordingStrategy{strategy: fixedStrategy{blackjack.Split}}
+
+			playRoundWithStrategy(t, options, strategy, test.ranks...)
+
+			got := make([]decisionRecord, 0, len(strategy.decisions))
+			for _, decision := range strategy.decisions {
+				// The unseen cards are tested separately
+				decision.unseenCards = [core.King + 1]int{}
+				got = append(got, decision)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got decisions %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlayShuffleDecisionContextShoeState(t *testing.T) {
+	strategy := &recordingStrategy{strategy: fixedStrategy{}}
+	playRoundWithStrategy(t, testRulesOptions(), strategy, core.Ten, core.Seven, core.Eight, core.Nine, core.Three, core.Three, core.Four)
+
+	if len(strategy.decisions) != 1 {
+		t.Fatalf("got %d decisions, want 1", len(strategy.decisions))
+	}
+
+	// The hole card is unseen along with the cards left in the shoe
+	var want [core.King + 1]int
+	want[core.Seven] = 1
+	want[core.Three] = 2
+	want[core.Four] = 1
+	if got := strategy.decisions[0].unseenCards; got != want {
+		t.Errorf("got unseen cards %v, want %v", got, want)
+	}
+}
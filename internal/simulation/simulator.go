@@ -1,62 +1,144 @@
 package simulation
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"math/rand"
 	"os"
 	"runtime"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/jljl1337/blackjack-simulator/internal/betting"
 	"github.com/jljl1337/blackjack-simulator/internal/blackjack"
 	"github.com/jljl1337/blackjack-simulator/internal/core"
+	"github.com/jljl1337/blackjack-simulator/internal/counting"
 	"github.com/jljl1337/blackjack-simulator/internal/exporter"
 	"github.com/jljl1337/blackjack-simulator/internal/person"
 	"github.com/jljl1337/blackjack-simulator/internal/result"
 )
 
 type Simulator struct {
-	seed                int64
-	numShuffles         uint
-	numRounds           uint
-	numHands            uint
-	numDecks            uint
-	penetration         float64
-	csvFile             string
-	numWorkers          uint
-	verbose             bool
-	strategy            blackjack.Strategy
-	doubleAfterSplit    bool
-	hitAfterSplitAce    bool
-	splitAfterSplitAce  bool
-	doubleAfterSplitAce bool
-	maxNumHands         int
-	surrenderAllowed    bool
+	seed            int64
+	numShuffles     uint
+	numTrips        uint
+	numRounds       uint
+	numHands        uint
+	numDecks        uint
+	penetration     float64
+	csvFile         string
+	trueCountFile   string
+	numWorkers      uint
+	verbose         bool
+	strategy        blackjack.Strategy
+	insurancePolicy blackjack.InsurancePolicy
+	countingSystem  counting.System
+	betRamp         *betting.Ramp
+	bettingPolicy   betting.Policy
+	indexTable      *blackjack.IndexTable
+	wong            *betting.Wong
+	roundsPerHour   float64
+	bankroll        int64
+	rorRounds       int
+	sessionsPerTrip int
+	sessionHours    float64
+	stopLoss        int64
+	winGoal         int64
+	rules           Rules
+
+	kelly              bool
+	kellyFraction      float64
+	kellyTrueCountFile string
+	chipSize           int
+	minBet             int
+	maxBet             int
+
+	strategyOutputFile string
+
+	indexOutputFile   string
+	indexDecisions    []IndexDecision
+	indexMinTrueCount int
+	indexMaxTrueCount int
+	indexTrials       int
 }
 
 type Config struct {
-	Seed                int64   `json:"seed"`
-	NumShuffles         uint    `json:"numShuffles"`
-	NumRounds           uint    `json:"numRounds"`
-	NumHands            uint    `json:"numHands"`
-	NumDecks            uint    `json:"numDecks"`
-	Penetration         float64 `json:"penetration"`
-	DoubleAfterSplit    bool    `json:"doubleAfterSplit"`
-	HitAfterSplitAce    bool    `json:"hitAfterSplitAce"`
-	SplitAfterSplitAce  bool    `json:"splitAfterSplitAce"`
-	DoubleAfterSplitAce bool    `json:"doubleAfterSplitAce"`
-	MaxNumHands         *int    `json:"maxNumHands"`
-	SurrenderAllowed    *bool   `json:"surrenderAllowed"`
+	Seed                  int64          `json:"seed"`
+	NumShuffles           uint           `json:"numShuffles"`
+	NumRounds             uint           `json:"numRounds"`
+	NumHands              uint           `json:"numHands"`
+	NumTrips              uint           `json:"numTrips"`
+	SessionsPerTrip       int            `json:"sessionsPerTrip"`
+	SessionHours          float64        `json:"sessionHours"`
+	StopLoss              int64          `json:"stopLoss"`
+	WinGoal               int64          `json:"winGoal"`
+	NumDecks              uint           `json:"numDecks"`
+	Penetration           float64        `json:"penetration"`
+	DoubleRule            string         `json:"doubleRule"`
+	DoubleAfterSplit      bool           `json:"doubleAfterSplit"`
+	HitAfterSplitAce      bool           `json:"hitAfterSplitAce"`
+	SplitAfterSplitAce    bool           `json:"splitAfterSplitAce"`
+	DoubleAfterSplitAce   bool           `json:"doubleAfterSplitAce"`
+	MaxNumHands           *int           `json:"maxNumHands"`
+	MaxNumHandsByRank     map[string]int `json:"maxNumHandsByRank"`
+	SplitUnlikeTens       bool           `json:"splitUnlikeTens"`
+	SurrenderAllowed      *bool          `json:"surrenderAllowed"`
+	DealerHitsSoft17      bool           `json:"dealerHitsSoft17"`
+	BlackjackPayout       string         `json:"blackjackPayout"`
+	SuitedBlackjackPayout string         `json:"suitedBlackjackPayout"`
+	BlackjackAfterSplit   bool           `json:"blackjackAfterSplit"`
+	InsurancePolicy       string         `json:"insurancePolicy"`
+	InsuranceThreshold    *float64       `json:"insuranceThreshold"`
+	HoleCardRule          string         `json:"holeCardRule"`
+	CharlieCards          int            `json:"charlieCards"`
+	CharliePayout         string         `json:"charliePayout"`
+	DealerPush22          bool           `json:"dealerPush22"`
+	NaturalBeatsDealer22  *bool          `json:"naturalBeatsDealer22"`
+	Strategy              string         `json:"strategy"`
+	StrategyChart         string         `json:"strategyChart"`
+	CountingSystem        string         `json:"countingSystem"`
+	CountingSystemFile    string         `json:"countingSystemFile"`
+	BetUnit               int            `json:"betUnit"`
+	BetRamp               map[string]int `json:"betRamp"`
+	MinBet                int            `json:"minBet"`
+	MaxBet                int            `json:"maxBet"`
+	BetSizing             string         `json:"betSizing"`
+	KellyFraction         float64        `json:"kellyFraction"`
+	KellyTrueCountFile    string         `json:"kellyTrueCountFile"`
+	ChipSize              int            `json:"chipSize"`
+	WongIn                *float64       `json:"wongIn"`
+	WongOut               *float64       `json:"wongOut"`
+	NoMidShoeEntry        bool           `json:"noMidShoeEntry"`
+	RoundsPerHour         float64        `json:"roundsPerHour"`
+	Bankroll              int64          `json:"bankroll"`
+	RorRounds             int            `json:"rorRounds"`
+	IndexTables           []string       `json:"indexTables"`
+	IndexTableFiles       []string       `json:"indexTableFiles"`
+	IndexDecisions        []string       `json:"indexDecisions"`
+	IndexMinTrueCount     *int           `json:"indexMinTrueCount"`
+	IndexMaxTrueCount     *int           `json:"indexMaxTrueCount"`
+	IndexTrials           int            `json:"indexTrials"`
+	SurrenderRule         string         `json:"surrenderRule"`
+	SurrenderAfterSplit   bool           `json:"surrenderAfterSplit"`
+	SurrenderAfterDouble  bool           `json:"surrenderAfterDouble"`
 }
 
 func NewSimulator() (*Simulator, error) {
 	configFile := flag.String("config", "config.json", "Path to configuration file")
 	csvFile := flag.String("csv", "", "CSV file to export results to")
+	trueCountFile := flag.String("true-count-csv", "", "CSV file to export results by true count to")
 	numWorkers := flag.Uint("num-workers", 0, "Number of workers to use for concurrent processing")
 	verbose := flag.Bool("verbose", false, "Enable verbose logging")
+	strategyFile := flag.String("strategy", "", "CSV file of the basic strategy to play, overriding the strategy in the configuration file")
+	strategyOutputFile := flag.String("generate-strategy", "", "Generate the basic strategy for the rules and write it to this file instead of running the simulation")
+	indexOutputFile := flag.String("generate-indexes", "", "Generate an index table for indexDecisions and write it to this file instead of running the simulation")
 
 	flag.Parse()
 
@@ -76,41 +158,395 @@ func NewSimulator() (*Simulator, error) {
 	log.Printf("Using seed: %d\n", config.Seed)
 	log.Printf("Number of workers: %d\n", *numWorkers)
 
-	strategy, err := blackjack.NewBasicStrategyS17()
+	maxNumHands := 4
+	if config.MaxNumHands != nil {
+		maxNumHands = *config.MaxNumHands
+	}
+
+	for rank := range config.MaxNumHandsByRank {
+		switch rank {
+		case "2", "3", "4", "5", "6", "7", "8", "9", "10", "A":
+		default:
+			return nil, fmt.Errorf("invalid rank in maxNumHandsByRank: %s", rank)
+		}
+	}
+
+	surrenderRule := SurrenderLate
+	if config.SurrenderAllowed != nil && !*config.SurrenderAllowed {
+		surrenderRule = SurrenderNone
+	}
+	if config.SurrenderRule != "" {
+		surrenderRule, err = ParseSurrenderRule(config.SurrenderRule)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing surrenderRule: %w", err)
+		}
+		if config.SurrenderAllowed != nil && !*config.SurrenderAllowed && surrenderRule != SurrenderNone {
+			return nil, fmt.Errorf("surrenderRule %s conflicts with surrenderAllowed set to false", surrenderRule)
+		}
+	}
+
+	if *strategyFile != "" {
+		config.Strategy = *strategyFile
+		config.StrategyChart = ""
+	}
+
+	strategy, err := newStrategy(config, surrenderRule != SurrenderNone)
 	if err != nil {
 		return nil, fmt.Errorf("error creating strategy: %w", err)
 	}
 
-	maxNumHands := 4
-	if config.MaxNumHands != nil {
-		maxNumHands = *config.MaxNumHands
+	blackjackPayout := blackjack.ThreeToTwo
+	if config.BlackjackPayout != "" {
+		blackjackPayout, err = blackjack.ParsePayout(config.BlackjackPayout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing blackjackPayout: %w", err)
+		}
+	}
+
+	var suitedBlackjackPayout blackjack.Payout
+	if config.SuitedBlackjackPayout != "" {
+		suitedBlackjackPayout, err = blackjack.ParsePayout(config.SuitedBlackjackPayout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing suitedBlackjackPayout: %w", err)
+		}
+	}
+
+	insurancePolicyName := "never"
+	if config.InsurancePolicy != "" {
+		insurancePolicyName = config.InsurancePolicy
+	}
+
+	insuranceThreshold := blackjack.DefaultInsuranceThreshold(insurancePolicyName)
+	if config.InsuranceThreshold != nil {
+		insuranceThreshold = *config.InsuranceThreshold
+	}
+
+	insurancePolicy, err := blackjack.NewInsurancePolicy(insurancePolicyName, insuranceThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("error creating insurance policy: %w", err)
+	}
+
+	holeCardRule := HoleCardPeek
+	if config.HoleCardRule != "" {
+		holeCardRule, err = ParseHoleCardRule(config.HoleCardRule)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing holeCardRule: %w", err)
+		}
+	}
+
+	if config.CharlieCards < 0 || (config.CharlieCards > 0 && config.CharlieCards < 3) {
+		return nil, fmt.Errorf("charlieCards must be 0 or at least 3")
+	}
+
+	charliePayout := blackjack.EvenMoney
+	if config.CharliePayout != "" {
+		charliePayout, err = blackjack.ParsePayout(config.CharliePayout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing charliePayout: %w", err)
+		}
+		if charliePayout.Numerator == 0 {
+			return nil, fmt.Errorf("charliePayout must pay more than 0")
+		}
+	}
+
+	naturalBeatsDealer22 := true
+	if config.NaturalBeatsDealer22 != nil {
+		naturalBeatsDealer22 = *config.NaturalBeatsDealer22
+	}
+
+	doubleRule := DoubleAnyTwo
+	if config.DoubleRule != "" {
+		doubleRule, err = ParseDoubleRule(config.DoubleRule)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing doubleRule: %w", err)
+		}
+	}
+
+	rules := NewRules(RulesOptions{
+		DoubleRule:            doubleRule,
+		DoubleAfterSplit:      config.DoubleAfterSplit,
+		HitAfterSplitAce:      config.HitAfterSplitAce,
+		SplitAfterSplitAce:    config.SplitAfterSplitAce,
+		DoubleAfterSplitAce:   config.DoubleAfterSplitAce,
+		MaxNumHands:           maxNumHands,
+		MaxNumHandsByRank:     config.MaxNumHandsByRank,
+		SplitUnlikeTens:       config.SplitUnlikeTens,
+		SurrenderRule:         surrenderRule,
+		SurrenderAfterSplit:   config.SurrenderAfterSplit,
+		SurrenderAfterDouble:  config.SurrenderAfterDouble,
+		DealerHitsSoft17:      config.DealerHitsSoft17,
+		BlackjackPayout:       blackjackPayout,
+		SuitedBlackjackPayout: suitedBlackjackPayout,
+		BlackjackAfterSplit:   config.BlackjackAfterSplit,
+		HoleCardRule:          holeCardRule,
+		CharlieCards:          config.CharlieCards,
+		CharliePayout:         charliePayout,
+		DealerPush22:          config.DealerPush22,
+		NaturalBeatsDealer22:  naturalBeatsDealer22,
+	})
+
+	countingSystem, err := newCountingSystem(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating counting system: %w", err)
+	}
+
+	log.Printf("Counting system: %s\n", countingSystem.Name)
+
+	betRamp, err := newBetRamp(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating bet ramp: %w", err)
+	}
+
+	wong, err := newWong(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating wong: %w", err)
 	}
 
-	surrenderAllowed := true
-	if config.SurrenderAllowed != nil {
-		surrenderAllowed = *config.SurrenderAllowed
+	roundsPerHour := 100.0
+	if config.RoundsPerHour < 0 {
+		return nil, fmt.Errorf("roundsPerHour must not be negative")
+	}
+	if config.RoundsPerHour > 0 {
+		roundsPerHour = config.RoundsPerHour
+	}
+
+	if config.SessionsPerTrip < 0 || config.StopLoss < 0 || config.WinGoal < 0 {
+		return nil, fmt.Errorf("sessionsPerTrip, stopLoss and winGoal must not be negative")
+	}
+
+	sessionsPerTrip := 1
+	if config.SessionsPerTrip > 0 {
+		sessionsPerTrip = config.SessionsPerTrip
+	}
+
+	if config.Bankroll < 0 {
+		return nil, fmt.Errorf("bankroll must not be negative")
+	}
+
+	rorRounds := 100000
+	if config.RorRounds < 0 {
+		return nil, fmt.Errorf("rorRounds must not be negative")
+	}
+	if config.RorRounds > 0 {
+		rorRounds = config.RorRounds
+	}
+
+	kelly := false
+	switch config.BetSizing {
+	case "", "ramp":
+	case "kelly":
+		kelly = true
+	default:
+		return nil, fmt.Errorf("invalid betSizing: %s", config.BetSizing)
+	}
+
+	if kelly && config.Bankroll == 0 {
+		return nil, fmt.Errorf("bankroll must be set with the kelly betSizing")
+	}
+
+	if kelly && config.NumTrips > 0 && config.KellyTrueCountFile == "" {
+		return nil, fmt.Errorf("kellyTrueCountFile must be set with the kelly betSizing and numTrips")
+	}
+
+	kellyFraction := 1.0
+	if config.KellyFraction < 0 {
+		return nil, fmt.Errorf("kellyFraction must not be negative")
+	}
+	if config.KellyFraction > 0 {
+		kellyFraction = config.KellyFraction
+	}
+
+	chipSize := 1
+	if config.ChipSize < 0 {
+		return nil, fmt.Errorf("chipSize must not be negative")
+	}
+	if config.ChipSize > 0 {
+		chipSize = config.ChipSize
+	}
+
+	var indexTable *blackjack.IndexTable
+	if len(config.IndexTables) > 0 || len(config.IndexTableFiles) > 0 {
+		indexTable, err = blackjack.NewIndexTable(config.IndexTables, config.IndexTableFiles)
+		if err != nil {
+			return nil, fmt.Errorf("error creating index table: %w", err)
+		}
+		log.Printf("Index table: %d indexes\n", indexTable.Len())
+	}
+
+	var indexDecisions []IndexDecision
+	for _, name := range config.IndexDecisions {
+		decision, err := ParseIndexDecision(name, config.SplitUnlikeTens)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing indexDecisions: %w", err)
+		}
+		indexDecisions = append(indexDecisions, decision)
+	}
+
+	if *indexOutputFile != "" && len(indexDecisions) == 0 {
+		return nil, fmt.Errorf("indexDecisions must be set to generate indexes")
+	}
+
+	indexMinTrueCount := -5
+	if config.IndexMinTrueCount != nil {
+		indexMinTrueCount = *config.IndexMinTrueCount
+	}
+
+	indexMaxTrueCount := 10
+	if config.IndexMaxTrueCount != nil {
+		indexMaxTrueCount = *config.IndexMaxTrueCount
+	}
+
+	if indexMaxTrueCount <= indexMinTrueCount {
+		return nil, fmt.Errorf("indexMaxTrueCount must be greater than indexMinTrueCount")
+	}
+
+	indexTrials := 50000
+	if config.IndexTrials > 0 {
+		indexTrials = config.IndexTrials
 	}
 
 	return &Simulator{
-		seed:                config.Seed,
-		numShuffles:         config.NumShuffles,
-		numDecks:            config.NumDecks,
-		numRounds:           config.NumRounds,
-		numHands:            config.NumHands,
-		penetration:         config.Penetration,
-		csvFile:             *csvFile,
-		numWorkers:          *numWorkers,
-		verbose:             *verbose,
-		strategy:            strategy,
-		doubleAfterSplit:    config.DoubleAfterSplit,
-		hitAfterSplitAce:    config.HitAfterSplitAce,
-		splitAfterSplitAce:  config.SplitAfterSplitAce,
-		doubleAfterSplitAce: config.DoubleAfterSplitAce,
-		maxNumHands:         maxNumHands,
-		surrenderAllowed:    surrenderAllowed,
+		seed:            config.Seed,
+		numShuffles:     config.NumShuffles,
+		numDecks:        config.NumDecks,
+		numRounds:       config.NumRounds,
+		numHands:        config.NumHands,
+		penetration:     config.Penetration,
+		csvFile:         *csvFile,
+		trueCountFile:   *trueCountFile,
+		numWorkers:      *numWorkers,
+		verbose:         *verbose,
+		strategy:        strategy,
+		insurancePolicy: insurancePolicy,
+		countingSystem:  countingSystem,
+		betRamp:         betRamp,
+		bettingPolicy:   betRamp,
+		indexTable:      indexTable,
+		wong:            wong,
+		roundsPerHour:   roundsPerHour,
+		bankroll:        config.Bankroll,
+		rorRounds:       rorRounds,
+		numTrips:        config.NumTrips,
+		sessionsPerTrip: sessionsPerTrip,
+		sessionHours:    config.SessionHours,
+		stopLoss:        config.StopLoss,
+		winGoal:         config.WinGoal,
+		rules:           rules,
+
+		kelly:              kelly,
+		kellyFraction:      kellyFraction,
+		kellyTrueCountFile: config.KellyTrueCountFile,
+		chipSize:           chipSize,
+		minBet:             config.MinBet,
+		maxBet:             config.MaxBet,
+
+		strategyOutputFile: *strategyOutputFile,
+
+		indexOutputFile:   *indexOutputFile,
+		indexDecisions:    indexDecisions,
+		indexMinTrueCount: indexMinTrueCount,
+		indexMaxTrueCount: indexMaxTrueCount,
+		indexTrials:       indexTrials,
 	}, nil
 }
 
+// newWong returns the wong to enter and leave the game by the true count, or
+// nil if the player plays every round.
+func newWong(config Config) (*betting.Wong, error) {
+	if config.WongIn == nil {
+		if config.WongOut != nil || config.NoMidShoeEntry {
+			return nil, fmt.Errorf("wongOut and noMidShoeEntry require wongIn")
+		}
+		return nil, nil
+	}
+
+	// Without an exit, the player leaves when the count falls below the entry
+	wongOut := *config.WongIn
+	if config.WongOut != nil {
+		wongOut = *config.WongOut
+	}
+
+	return betting.NewWong(*config.WongIn, wongOut, config.NoMidShoeEntry)
+}
+
+// newStrategy returns the basic strategy from the CSV file if specified,
+// otherwise the built-in chart by name, defaulting to the chart for the
+// number of decks, dealer rule, double after split and surrender. It logs
+// the chart chosen or the SHA-256 hash of the file to identify the strategy
+// played.
+func newStrategy(config Config, surrender bool) (blackjack.Strategy, error) {
+	if config.Strategy == "" {
+		chart := config.StrategyChart
+		source := "override"
+		if chart == "" {
+			chart = blackjack.ChartName(config.NumDecks, config.DealerHitsSoft17, config.DoubleAfterSplit, surrender)
+			source = "selected from the rules"
+		}
+
+		log.Printf("Strategy: built-in chart %s (%s)\n", chart, source)
+		return blackjack.NewBasicStrategyFromChart(chart)
+	}
+
+	if config.StrategyChart != "" {
+		return nil, fmt.Errorf("strategy and strategyChart are mutually exclusive")
+	}
+
+	bytes, err := os.ReadFile(config.Strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy, err := blackjack.NewCompositionStrategyFromCSV(string(bytes))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", config.Strategy, err)
+	}
+
+	log.Printf("Strategy: %s (SHA-256 %x)\n", config.Strategy, sha256.Sum256(bytes))
+	if strategy.NumRules() == 0 {
+		return strategy.TotalDependent(), nil
+	}
+
+	log.Printf("Composition rules: %d\n", strategy.NumRules())
+	return strategy, nil
+}
+
+// newCountingSystem returns the counting system from the file if specified,
+// otherwise the built-in system by name, defaulting to Hi-Lo.
+func newCountingSystem(config Config) (counting.System, error) {
+	if config.CountingSystemFile != "" {
+		if config.CountingSystem != "" {
+			return counting.System{}, fmt.Errorf("countingSystem and countingSystemFile are mutually exclusive")
+		}
+		return counting.LoadSystemFromFile(config.CountingSystemFile)
+	}
+
+	if config.CountingSystem != "" {
+		return counting.GetSystem(config.CountingSystem)
+	}
+
+	return counting.GetSystem("hiLo")
+}
+
+// newBetRamp returns the bet ramp from the config. Without a ramp, the player
+// flat bets a single unit of 100 by default.
+func newBetRamp(config Config) (*betting.Ramp, error) {
+	unit := 100
+	if config.BetUnit != 0 {
+		unit = config.BetUnit
+	}
+
+	unitsByTrueCount := make(map[int]int, len(config.BetRamp))
+	for trueCountString, units := range config.BetRamp {
+		trueCount, err := strconv.Atoi(trueCountString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid true count in betRamp: %s", trueCountString)
+		}
+		unitsByTrueCount[trueCount] = units
+	}
+
+	return betting.NewRamp(unit, unitsByTrueCount, config.MinBet, config.MaxBet)
+}
+
 func readConfig(configFile string) (Config, error) {
 	// Open the JSON file
 	file, err := os.Open(configFile)
@@ -148,9 +584,16 @@ func readConfig(configFile string) (Config, error) {
 	if config.NumHands > 0 {
 		conditionCount++
 	}
+	if config.NumTrips > 0 {
+		conditionCount++
+	}
 
 	if conditionCount != 1 {
-		return Config{}, fmt.Errorf("exactly one of numShuffles, numRounds, or numHands must be set to a value greater than 0")
+		return Config{}, fmt.Errorf("exactly one of numShuffles, numRounds, numHands, or numTrips must be set to a value greater than 0")
+	}
+
+	if config.NumTrips > 0 && config.SessionHours <= 0 {
+		return Config{}, fmt.Errorf("sessionHours must be set to a value greater than 0 with numTrips")
 	}
 
 	if config.NumDecks <= 0 {
@@ -170,15 +613,199 @@ func readConfig(configFile string) (Config, error) {
 }
 
 func (s *Simulator) Run() error {
+	if s.strategyOutputFile != "" {
+		return s.generateStrategy()
+	}
+
+	if s.indexOutputFile != "" {
+		return s.generateIndexes()
+	}
+
+	if s.kelly {
+		if err := s.setUpKelly(); err != nil {
+			return fmt.Errorf("error setting up Kelly bet sizing: %w", err)
+		}
+	}
+
+	if s.numTrips > 0 {
+		return s.runTrips()
+	}
+
+	summary, ruinTracker, shuffleResults, err := s.playShuffles()
+	if err != nil {
+		return err
+	}
+
+	averageBalance := float64(summary.Balance) / float64(summary.NumShuffles)
+
+	log.Printf("Dealer rule: %s\n", s.rules.DealerRule())
+	log.Printf("Hole card rule: %s\n", s.rules.HoleCardRule())
+	log.Printf("Double rule: %s\n", s.rules.DoubleRule())
+	log.Printf("Surrender rule: %s\n", s.rules.SurrenderRule())
+	log.Printf("Blackjack payout: %s\n", s.rules.BlackjackPayout())
+	if suitedPayout := s.rules.SuitedBlackjackPayout(); !suitedPayout.IsZero() {
+		log.Printf("Suited blackjack payout: %s\n", suitedPayout)
+	}
+	if charlieCards := s.rules.CharlieCards(); charlieCards > 0 {
+		log.Printf("%d-card Charlie pays: %s\n", charlieCards, s.rules.CharliePayout())
+	}
+	if s.rules.DealerPush22() {
+		log.Printf("Dealer 22 pushes\n")
+	}
+	if s.rules.BlackjackAfterSplit() {
+		log.Printf("Two-card 21s after splitting are paid as blackjack\n")
+	}
+	log.Printf("Average balance: %.2f\n", averageBalance)
+	log.Printf("Total balance: %d\n", summary.Balance)
+	log.Printf("Total insurance wagered: %d\n", summary.InsuranceBetPlaced)
+	log.Printf("Total insurance balance: %d\n", summary.InsuranceBalance)
+	log.Printf("Even money taken: %d\n", summary.NumEvenMoney)
+
+	// Kelly bets are not sized in betting units, so its results are shown in
+	// currency
+	unit, unitName := s.betRamp.Unit(), fmt.Sprintf("units of %d", s.betRamp.Unit())
+	if s.kelly {
+		unit, unitName = 1, "currency"
+	}
+	if s.wong != nil {
+		log.Printf("Rounds observed: %d\n", summary.NumRoundsObserved)
+		log.Printf("Rounds played: %d\n", summary.NumRoundsPlayed())
+	}
+	log.Printf("Average initial bet: %.2f\n", summary.AverageInitialBet())
+	log.Printf("Win rate: %.4f %s per 100 hands\n", summary.WinRatePer100Hands(unit), unitName)
+	log.Printf("Win rate: %.4f %s per 100 rounds\n", summary.WinRatePer100Rounds(unit), unitName)
+	log.Printf("Standard deviation: %.4f %s per round\n", summary.StandardDeviation(unit), unitName)
+	log.Printf("Win rate: %.4f %s per hour at %g rounds per hour\n", summary.WinRatePerHour(unit, s.roundsPerHour), unitName, s.roundsPerHour)
+	log.Printf("N0 (per round): %.0f rounds\n", summary.N0())
+	log.Printf("Desirability index (per round): %.4f\n", summary.DesirabilityIndex())
+	log.Printf("SCORE (per round): %.4f\n", summary.Score())
+
+	if s.bankroll > 0 {
+		bankroll := float64(s.bankroll)
+		if s.kelly {
+			log.Printf("Bankroll: %d\n", s.bankroll)
+		} else {
+			log.Printf("Bankroll: %d (%.1f units)\n", s.bankroll, bankroll/float64(unit))
+		}
+		log.Printf("Risk of ruin (closed form): %.4f%%\n", summary.RiskOfRuin(bankroll)*100)
+		log.Printf("Risk of ruin within %d rounds (closed form): %.4f%%\n", s.rorRounds, summary.RiskOfRuinWithin(bankroll, s.rorRounds)*100)
+		log.Printf("Growth rate: %.6f%% of the bankroll per round\n", summary.GrowthRate(bankroll)*100)
+
+		riskOfRuin, numWindows := ruinTracker.RiskOfRuin()
+		if numWindows >= result.MinRuinWindows {
+			log.Printf("Risk of ruin within %d rounds (simulated over %d independent windows): %.4f%%\n", s.rorRounds, numWindows, riskOfRuin*100)
+		} else {
+			log.Printf("Risk of ruin within %d rounds (simulated): not reported, %d independent windows played and at least %d needed, play more rounds or lower rorRounds\n", s.rorRounds, numWindows, result.MinRuinWindows)
+		}
+	}
+
+	if s.indexTable != nil {
+		names := make([]string, 0, len(summary.DeviationCounts))
+		for name := range summary.DeviationCounts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		log.Printf("Deviations played:\n")
+		for _, name := range names {
+			log.Printf("  %s: %d\n", name, summary.DeviationCounts[name])
+		}
+	}
+
+	trueCountBuckets := summary.TrueCountBuckets()
+	numBucketRounds := 0
+	for _, bucket := range trueCountBuckets {
+		numBucketRounds += bucket.NumRounds
+	}
+
+	log.Printf("Results by true count:\n")
+	for _, bucket := range trueCountBuckets {
+		log.Printf("  %+3d: %6.2f%% of rounds, average result %+.4f, variance %.4f\n",
+			bucket.TrueCount,
+			float64(bucket.NumRounds)/float64(numBucketRounds)*100,
+			bucket.AverageResult(),
+			bucket.Variance(),
+		)
+	}
+
+	if compositionStrategy, ok := s.strategy.(*blackjack.CompositionStrategy); ok {
+		if err := s.logCompositionGain(summary, compositionStrategy); err != nil {
+			return err
+		}
+	}
+
+	if s.trueCountFile != "" {
+		trueCountExporter := exporter.NewTrueCountCSVExporter(s.trueCountFile)
+		log.Printf("Exporting results by true count to CSV...\n")
+		if err := trueCountExporter.Export(trueCountBuckets); err != nil {
+			return fmt.Errorf("error exporting results by true count to CSV: %w", err)
+		}
+	}
+
+	if s.csvFile != "" {
+		csvExporter := exporter.NewCSVExporter(s.csvFile, s.rules.DealerRule())
+		log.Printf("Exporting results to CSV...\n")
+		if err := csvExporter.Export(shuffleResults); err != nil {
+			return fmt.Errorf("error exporting results to CSV: %w", err)
+		}
+	}
+
+	log.Printf("Simulation completed successfully\n")
+	return nil
+}
+
+// logCompositionGain plays the same shuffles with the total-dependent
+// strategy under the composition rules and logs the expected value gained by
+// the rules per initial bet.
+func (s *Simulator) logCompositionGain(summary result.Summary, strategy *blackjack.CompositionStrategy) error {
+	totalDependent := *s
+	totalDependent.strategy = strategy.TotalDependent()
+	totalDependent.bankroll = 0
+	totalDependent.csvFile = ""
+
+	log.Printf("Playing the total-dependent strategy for comparison...\n")
+	totalDependentSummary, _, _, err := totalDependent.playShuffles()
+	if err != nil {
+		return fmt.Errorf("error playing the total-dependent strategy: %w", err)
+	}
+
+	compositionEV := summary.ExpectedValue()
+	totalDependentEV := totalDependentSummary.ExpectedValue()
+
+	// The shoes diverge after the first decision changed by a rule, so the
+	// results are treated as independent
+	standardError := math.Hypot(summary.ExpectedValueStandardError(), totalDependentSummary.ExpectedValueStandardError())
+
+	log.Printf("Expected value: %+.4f%% composition-dependent, %+.4f%% total-dependent\n", compositionEV*100, totalDependentEV*100)
+	log.Printf("Composition-dependent gain: %+.4f%% of the initial bet (standard error %.4f%%)\n", (compositionEV-totalDependentEV)*100, standardError*100)
+	return nil
+}
+
+// playShuffles plays shuffles in parallel until the number of shuffles,
+// rounds or hands is reached, and adds them to the summary in order. The
+// rounds of the shuffles are only kept when exporting them to CSV.
+func (s *Simulator) playShuffles() (result.Summary, *result.RuinTracker, []result.ShuffleResult, error) {
 	random := rand.New(rand.NewSource(s.seed))
 
 	inputChan := make(chan ShuffleInput, s.numWorkers)
 	resultChan := make(chan result.ShuffleResult, s.numWorkers)
 
-	// Start consumer workers
+	// Start consumer workers, which are stopped and waited for on return so
+	// that repeated calls do not leave them running
+	done := make(chan struct{})
+	var wg sync.WaitGroup
 	for range s.numWorkers {
-		go PlayShuffleWorker(inputChan, resultChan)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			PlayShuffleWorker(inputChan, resultChan, done)
+		}()
 	}
+	defer func() {
+		close(done)
+		close(inputChan)
+		wg.Wait()
+	}()
 
 	shuffleId := uint(0)
 
@@ -196,11 +823,15 @@ func (s *Simulator) Run() error {
 	count := uint(0)
 	countedShuffles := uint(0)
 
+	// Shuffles are added to the summary in order as they are counted
+	var summary result.Summary
+	ruinTracker := result.NewRuinTracker(s.bankroll, s.rorRounds)
+
 out:
 	for {
 		shuffleResult := <-resultChan
 		if shuffleResult.Error != nil {
-			return fmt.Errorf("error in shuffle %d: %w", shuffleResult.ShuffleId, shuffleResult.Error)
+			return result.Summary{}, nil, nil, fmt.Errorf("error in shuffle %d: %w", shuffleResult.ShuffleId, shuffleResult.Error)
 		}
 
 		if s.verbose {
@@ -219,6 +850,15 @@ out:
 
 				countedShuffles++
 
+				summary.Add(shuffleResults[i])
+				if s.bankroll > 0 {
+					ruinTracker.Add(shuffleResults[i])
+				}
+				if s.csvFile == "" {
+					// The rounds are only kept to export them
+					shuffleResults[i].RoundResults = nil
+				}
+
 				if s.numShuffles > 0 {
 					count++
 					if count >= s.numShuffles {
@@ -242,7 +882,6 @@ out:
 
 				if finish {
 					log.Printf("Finished %d shuffles using %.3f seconds\n", countedShuffles, time.Since(startTime).Seconds())
-					close(inputChan)
 					break out
 				}
 			}
@@ -255,40 +894,92 @@ out:
 
 	shuffleResults = shuffleResults[:countedShuffles]
 
-	var balanceSum int64
-	for _, result := range shuffleResults {
-		balanceSum += int64(result.Balance)
-	}
-	averageBalance := float64(balanceSum) / float64(countedShuffles)
+	return summary, ruinTracker, shuffleResults, nil
+}
 
-	log.Printf("Average balance: %.2f\n", averageBalance)
-	log.Printf("Total balance: %d\n", balanceSum)
+// setUpKelly replaces the betting policy with Kelly bet sizing for the edges
+// by true count, read from kellyTrueCountFile or otherwise measured by
+// flat betting every round of the same number of shuffles, rounds or hands
+// with another seed.
+func (s *Simulator) setUpKelly() error {
+	var edges []betting.Edge
+	if s.kellyTrueCountFile != "" {
+		var err error
+		edges, err = betting.LoadEdgesFromCSV(s.kellyTrueCountFile)
+		if err != nil {
+			return fmt.Errorf("error loading %s: %w", s.kellyTrueCountFile, err)
+		}
+	} else {
+		flatBet, err := betting.NewFlatBet(s.betRamp.Unit())
+		if err != nil {
+			return err
+		}
 
-	if s.csvFile != "" {
-		csvExporter := exporter.NewCSVExporter(s.csvFile)
-		log.Printf("Exporting results to CSV...\n")
-		if err := csvExporter.Export(shuffleResults); err != nil {
-			return fmt.Errorf("error exporting results to CSV: %w", err)
+		measurement := *s
+		measurement.seed = s.seed + 1
+		measurement.bettingPolicy = flatBet
+		measurement.wong = nil
+		measurement.bankroll = 0
+		measurement.csvFile = ""
+
+		log.Printf("Measuring the edge by true count...\n")
+		summary, _, _, err := measurement.playShuffles()
+		if err != nil {
+			return err
+		}
+
+		for _, bucket := range summary.TrueCountBuckets() {
+			edges = append(edges, betting.Edge{
+				TrueCount:     bucket.TrueCount,
+				NumRounds:     bucket.NumRounds,
+				AverageResult: bucket.AverageResult(),
+				Variance:      bucket.Variance(),
+			})
 		}
 	}
 
-	log.Printf("Simulation completed successfully\n")
+	kelly, err := betting.NewKelly(s.bankroll, s.kellyFraction, edges, s.chipSize, s.minBet, s.maxBet)
+	if err != nil {
+		return err
+	}
+	s.bettingPolicy = kelly
+
+	bets := kelly.Bets()
+	trueCounts := make([]int, 0, len(bets))
+	for trueCount := range bets {
+		trueCounts = append(trueCounts, trueCount)
+	}
+	sort.Ints(trueCounts)
+
+	intercept, slope := kelly.FittedEdge()
+	log.Printf("Fitted edge: %+.4f%% %+.4f%% per true count\n", intercept*100, slope*100)
+	log.Printf("Kelly bets at %g of the Kelly bet for a bankroll of %d:\n", s.kellyFraction, s.bankroll)
+	for _, trueCount := range trueCounts {
+		log.Printf("  %+3d: %d\n", trueCount, bets[trueCount])
+	}
+
 	return nil
 }
 
 func (s *Simulator) sendInput(inputChan chan<- ShuffleInput, shuffleId uint, random *rand.Rand) {
-	player := person.NewPlayer(s.strategy)
+	inputChan <- s.newShuffleInput(shuffleId, random)
+}
+
+// newShuffleInput creates the input of a shuffle with a new player and shoe.
+func (s *Simulator) newShuffleInput(shuffleId uint, random *rand.Rand) ShuffleInput {
+	player := person.NewPlayer(s.strategy, s.insurancePolicy, s.bettingPolicy, s.indexTable, s.wong, s.rules.SplitUnlikeTens())
 	dealer := person.NewDealer()
-	rules := NewRules(s.doubleAfterSplit, s.hitAfterSplitAce, s.splitAfterSplitAce, s.doubleAfterSplitAce, s.maxNumHands, s.surrenderAllowed)
 	shoe := core.NewShoe(s.numDecks, s.penetration, random)
+	counter := counting.NewCounter(s.countingSystem, s.numDecks)
 
 	input := ShuffleInput{
 		ShuffleId: shuffleId,
 		Player:    *player,
 		Dealer:    *dealer,
 		Shoe:      *shoe,
-		Rules:     rules,
+		Counter:   *counter,
+		Rules:     s.rules,
 	}
 
-	inputChan <- input
+	return input
 }
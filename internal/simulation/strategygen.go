@@ -0,0 +1,608 @@
+package simulation
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jljl1337/blackjack-simulator/internal/blackjack"
+	"github.com/jljl1337/blackjack-simulator/internal/core"
+	"github.com/jljl1337/blackjack-simulator/internal/person"
+)
+
+// cardValues are the card values of the strategy generator, 1 for an ace to
+// 10 for any ten-value card, indexed by value.
+type cardValues [11]int
+
+// dealerOutcomes are the probabilities of the dealer's final totals given no
+// dealer blackjack, indexed by total from 17 to 21, with 22 for a bust of
+// exactly 22 and 23 for a bust of more.
+type dealerOutcomes [24]float64
+
+// chartActions are the first actions considered for each chart cell, in the
+// order used to break ties.
+var chartActions = []blackjack.Action{blackjack.Stand, blackjack.Hit, blackjack.Double, blackjack.Split, blackjack.Surrender}
+
+// chartUpCards are the dealer up card values in the order of the chart
+// columns.
+var chartUpCards = []int{2, 3, 4, 5, 6, 7, 8, 9, 10, 1}
+
+// cellEVs accumulates the EV of each first action of a chart cell over the
+// two-card hands of the cell, weighted by their probability.
+type cellEVs struct {
+	weight float64
+	evs    map[blackjack.Action]float64
+}
+
+func (c *cellEVs) add(weight float64, evs map[blackjack.Action]float64) {
+	if c.evs == nil {
+		c.evs = make(map[blackjack.Action]float64, len(evs))
+	}
+	c.weight += weight
+	for action, ev := range evs {
+		c.evs[action] += weight * ev
+	}
+}
+
+// generateStrategy computes the total-dependent basic strategy that maximizes
+// the EV under the rules and number of decks by combinatorial analysis, and
+// writes it to a strategy file in the format of the built-in charts.
+//
+// For each dealer up card and two-card hand, the dealer's final totals are
+// computed exactly from the shoe less the known cards. The player's draws
+// are taken from the same shoe without further removal, and the EV of each
+// first action is averaged over the two-card hands of each chart row,
+// weighted by their probability. Splits are played once without resplitting.
+func (s *Simulator) generateStrategy() error {
+	startTime := time.Now()
+
+	var shoe cardValues
+	for value := 1; value <= 9; value++ {
+		shoe[value] = 4 * int(s.numDecks)
+	}
+	shoe[10] = 16 * int(s.numDecks)
+
+	// cells[row][upCard] are the EVs of each chart cell
+	cells := make(map[string]map[int]*cellEVs)
+
+	type upCardResult struct {
+		upCard int
+		cells  map[string]*cellEVs
+		err    error
+	}
+
+	upCardChan := make(chan int, len(chartUpCards))
+	resultChan := make(chan upCardResult, len(chartUpCards))
+
+	for range s.numWorkers {
+		go func() {
+			for upCard := range upCardChan {
+				upCardCells, err := s.strategyCells(shoe, upCard)
+				resultChan <- upCardResult{upCard: upCard, cells: upCardCells, err: err}
+			}
+		}()
+	}
+
+	for _, upCard := range chartUpCards {
+		upCardChan <- upCard
+	}
+	close(upCardChan)
+
+	for range chartUpCards {
+		upCardResult := <-resultChan
+		if upCardResult.err != nil {
+			return fmt.Errorf("error analyzing dealer up card %s: %w", valueString(upCardResult.upCard), upCardResult.err)
+		}
+		for row, cell := range upCardResult.cells {
+			if cells[row] == nil {
+				cells[row] = make(map[int]*cellEVs)
+			}
+			cells[row][upCardResult.upCard] = cell
+		}
+	}
+
+	log.Printf("Analyzed %d dealer up cards using %.3f seconds\n", len(chartUpCards), time.Since(startTime).Seconds())
+
+	header := []string{"PlayerHand"}
+	for _, upCard := range chartUpCards {
+		header = append(header, valueString(upCard))
+	}
+	records := [][]string{header}
+
+	for _, row := range chartRows() {
+		record := []string{row}
+		for _, upCard := range chartUpCards {
+			cell := cells[row][upCard]
+			if cell == nil {
+				// Hands that are never dealt on two cards, hard and soft 21
+				record = append(record, blackjack.Stand.String())
+				continue
+			}
+
+			actions := cellActions(cell, row[0] == 'P')
+			if s.verbose {
+				log.Printf("%s vs %s: %s (%s)\n", row, valueString(upCard), actions, formatCellEVs(cell))
+			}
+			record = append(record, actions)
+		}
+		records = append(records, record)
+	}
+
+	file, err := os.Create(s.strategyOutputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("error writing strategy: %w", err)
+	}
+
+	log.Printf("Strategy written to %s\n", s.strategyOutputFile)
+	return nil
+}
+
+// chartRows returns the rows of a strategy chart in order.
+func chartRows() []string {
+	var rows []string
+	for total := 4; total <= 21; total++ {
+		rows = append(rows, "H"+strconv.Itoa(total))
+	}
+	for total := 12; total <= 21; total++ {
+		rows = append(rows, "S"+strconv.Itoa(total))
+	}
+	for value := 2; value <= 10; value++ {
+		rows = append(rows, "P"+strconv.Itoa(value))
+	}
+	return append(rows, "PA")
+}
+
+// strategyCells returns the EVs of the chart cells against the dealer up
+// card by row. Hard and soft rows use the two-card hands that are not pairs,
+// or the pairs without splitting if no other hand makes the total.
+func (s *Simulator) strategyCells(shoe cardValues, upCard int) (map[string]*cellEVs, error) {
+	shoe[upCard]--
+
+	cells := make(map[string]*cellEVs)
+	pairTotalCells := make(map[string]*cellEVs)
+
+	numCards := 0
+	for _, count := range shoe {
+		numCards += count
+	}
+
+	for first := 1; first <= 10; first++ {
+		for second := first; second <= 10; second++ {
+			if first == 1 && second == 10 {
+				// Naturals are not played
+				continue
+			}
+
+			weight := float64(shoe[first]) / float64(numCards)
+			if first == second {
+				weight *= float64(shoe[second]-1) / float64(numCards-1)
+			} else {
+				weight *= 2 * float64(shoe[second]) / float64(numCards-1)
+			}
+			if weight == 0 {
+				continue
+			}
+
+			remaining := shoe
+			remaining[first]--
+			remaining[second]--
+
+			evs, err := s.twoCardEVs(remaining, upCard, first, second)
+			if err != nil {
+				return nil, err
+			}
+
+			totalRow := totalRowName(first, second)
+			if first != second {
+				addCell(cells, totalRow, weight, evs)
+				continue
+			}
+
+			addCell(cells, "P"+valueString(first), weight, evs)
+
+			totalEVs := make(map[blackjack.Action]float64, len(evs))
+			for action, ev := range evs {
+				if action != blackjack.Split {
+					totalEVs[action] = ev
+				}
+			}
+			addCell(pairTotalCells, totalRow, weight, totalEVs)
+		}
+	}
+
+	for row, cell := range pairTotalCells {
+		if _, exists := cells[row]; !exists {
+			cells[row] = cell
+		}
+	}
+
+	return cells, nil
+}
+
+func addCell(cells map[string]*cellEVs, row string, weight float64, evs map[blackjack.Action]float64) {
+	if cells[row] == nil {
+		cells[row] = &cellEVs{}
+	}
+	cells[row].add(weight, evs)
+}
+
+// totalRowName returns the hard or soft row of a two-card hand, e.g. H16 or
+// S18.
+func totalRowName(first, second int) string {
+	total := first + second
+	if first == 1 || second == 1 {
+		return "S" + strconv.Itoa(total+10)
+	}
+	return "H" + strconv.Itoa(total)
+}
+
+// cellActions returns the actions of a chart cell in order of EV, up to the
+// first action that is always allowed on the row, hit or stand, or split on
+// pair rows, whose row is the fallback.
+func cellActions(cell *cellEVs, pair bool) string {
+	actions := make([]blackjack.Action, 0, len(cell.evs))
+	for _, action := range chartActions {
+		if _, exists := cell.evs[action]; exists {
+			actions = append(actions, action)
+		}
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		return cell.evs[actions[i]] > cell.evs[actions[j]]
+	})
+
+	result := ""
+	for _, action := range actions {
+		result += action.String()
+		if action == blackjack.Hit || action == blackjack.Stand || (pair && action == blackjack.Split) {
+			break
+		}
+	}
+	return result
+}
+
+// formatCellEVs formats the average EV of each action of a cell.
+func formatCellEVs(cell *cellEVs) string {
+	result := ""
+	for _, action := range chartActions {
+		if ev, exists := cell.evs[action]; exists {
+			if result != "" {
+				result += ", "
+			}
+			result += fmt.Sprintf("%s %+.4f", action, ev/cell.weight)
+		}
+	}
+	return result
+}
+
+// twoCardEVs returns the EV of each legal first action of the two-card hand
+// against the dealer up card, with the rest of the shoe remaining.
+func (s *Simulator) twoCardEVs(remaining cardValues, upCard, first, second int) (map[blackjack.Action]float64, error) {
+	hand := analysisHand(s.rules.SplitUnlikeTens(), first, second)
+	actionsAllowed, err := s.rules.GetActionsAllowed(hand.Hand, 1, false)
+	if err != nil {
+		return nil, err
+	}
+
+	outcomes, dealerBlackjackProbability := s.dealerOutcomes(remaining, upCard)
+	player := newPlayerAnalysis(s.rules, remaining, outcomes)
+
+	// A dealer blackjack is settled when the dealer checks for it, before the
+	// player acts, unless the dealer has no hole card
+	blackjackProbability := dealerBlackjackProbability
+	if s.rules.DealerPeeks() {
+		blackjackProbability = 0
+	}
+
+	// blackjackLoss is the loss to a dealer blackjack with the given total
+	// wager in bets, of which only the original bet is lost under enhcObo
+	blackjackLoss := func(wager float64) float64 {
+		if s.rules.HoleCardRule() == NoHoleCardOBO {
+			return -1
+		}
+		return -wager
+	}
+
+	withBlackjack := func(ev, wager float64) float64 {
+		return (1-blackjackProbability)*ev + blackjackProbability*blackjackLoss(wager)
+	}
+
+	hard := first + second
+	hasAce := first == 1 || second == 1
+
+	evs := map[blackjack.Action]float64{
+		blackjack.Stand: withBlackjack(player.standEV(handTotal(hard, hasAce), 2), 1),
+	}
+
+	if actionsAllowed[blackjack.Hit] {
+		evs[blackjack.Hit] = withBlackjack(player.hitEV(hard, hasAce, 2), 1)
+	}
+
+	if actionsAllowed[blackjack.Double] {
+		evs[blackjack.Double] = withBlackjack(player.doubleEV(hard, hasAce, 2), 2)
+	}
+
+	if actionsAllowed[blackjack.Split] && first == second {
+		ev, err := player.splitEV(first)
+		if err != nil {
+			return nil, err
+		}
+		evs[blackjack.Split] = withBlackjack(ev, 2)
+	}
+
+	if actionsAllowed[blackjack.Surrender] {
+		surrenderEV := -0.5
+		if s.rules.DealerPeeks() && s.rules.CanSurrenderEarly(analysisCard(upCard)) {
+			// Early surrender is decided before the dealer checks for
+			// blackjack, which would lose the bet, so it is compared with
+			// the EVs given no dealer blackjack at the equivalent EV
+			surrenderEV = (-0.5 + dealerBlackjackProbability) / (1 - dealerBlackjackProbability)
+		}
+		evs[blackjack.Surrender] = surrenderEV
+	}
+
+	return evs, nil
+}
+
+// dealerOutcomes returns the probabilities of the dealer's final totals
+// against the up card given no dealer blackjack, drawing from the shoe
+// without replacement, and the probability of a dealer blackjack.
+func (s *Simulator) dealerOutcomes(shoe cardValues, upCard int) (dealerOutcomes, float64) {
+	numCards := 0
+	for _, count := range shoe {
+		numCards += count
+	}
+
+	// The hole card does not make a blackjack
+	blackjackCard := 0
+	switch upCard {
+	case 1:
+		blackjackCard = 10
+	case 10:
+		blackjackCard = 1
+	}
+
+	blackjackProbability := 0.0
+	if blackjackCard != 0 {
+		blackjackProbability = float64(shoe[blackjackCard]) / float64(numCards)
+	}
+
+	holeCards := numCards
+	if blackjackCard != 0 {
+		holeCards -= shoe[blackjackCard]
+	}
+
+	var outcomes dealerOutcomes
+	for holeCard := 1; holeCard <= 10; holeCard++ {
+		if holeCard == blackjackCard || shoe[holeCard] == 0 {
+			continue
+		}
+
+		probability := float64(shoe[holeCard]) / float64(holeCards)
+		shoe[holeCard]--
+		s.dealerDraw(&shoe, numCards-1, upCard+holeCard, upCard == 1 || holeCard == 1, probability, &outcomes)
+		shoe[holeCard]++
+	}
+
+	return outcomes, blackjackProbability
+}
+
+// dealerDraw adds the probabilities of the dealer's final totals from a hand
+// of the hard total, reached with the probability, to the outcomes.
+func (s *Simulator) dealerDraw(shoe *cardValues, numCards, hard int, hasAce bool, probability float64, outcomes *dealerOutcomes) {
+	total := handTotal(hard, hasAce)
+	soft := hasAce && hard+10 <= 21
+
+	switch {
+	case total > 22:
+		outcomes[23] += probability
+		return
+	case total == 22:
+		outcomes[22] += probability
+		return
+	case total > 17 || (total == 17 && !(soft && s.rules.DealerHitsSoft17())):
+		outcomes[total] += probability
+		return
+	}
+
+	for value := 1; value <= 10; value++ {
+		if shoe[value] == 0 {
+			continue
+		}
+
+		drawProbability := probability * float64(shoe[value]) / float64(numCards)
+		shoe[value]--
+		s.dealerDraw(shoe, numCards-1, hard+value, hasAce || value == 1, drawProbability, outcomes)
+		shoe[value]++
+	}
+}
+
+// playerAnalysis computes the EVs of the player's hands against the dealer's
+// final totals, drawing from the shoe with fixed probabilities.
+type playerAnalysis struct {
+	rules         Rules
+	probabilities [11]float64
+	outcomes      dealerOutcomes
+	// hitEVs memoizes the EV of hitting by hard total, ace and number of
+	// cards
+	hitEVs map[[3]int]float64
+}
+
+func newPlayerAnalysis(rules Rules, shoe cardValues, outcomes dealerOutcomes) *playerAnalysis {
+	numCards := 0
+	for _, count := range shoe {
+		numCards += count
+	}
+
+	var probabilities [11]float64
+	for value := 1; value <= 10; value++ {
+		probabilities[value] = float64(shoe[value]) / float64(numCards)
+	}
+
+	return &playerAnalysis{
+		rules:         rules,
+		probabilities: probabilities,
+		outcomes:      outcomes,
+		hitEVs:        make(map[[3]int]float64),
+	}
+}
+
+// standEV returns the EV of standing on the total with the number of cards.
+func (p *playerAnalysis) standEV(total, numCards int) float64 {
+	if total > 21 {
+		return -1
+	}
+	if p.isCharlie(numCards) {
+		return payoutValue(p.rules.CharliePayout())
+	}
+
+	ev := p.outcomes[23]
+	if !p.rules.DealerPush22() {
+		ev += p.outcomes[22]
+	}
+	for dealerTotal := 17; dealerTotal <= 21; dealerTotal++ {
+		if total > dealerTotal {
+			ev += p.outcomes[dealerTotal]
+		} else if total < dealerTotal {
+			ev -= p.outcomes[dealerTotal]
+		}
+	}
+	return ev
+}
+
+// hitEV returns the EV of hitting the hand and playing on by hitting or
+// standing.
+func (p *playerAnalysis) hitEV(hard int, hasAce bool, numCards int) float64 {
+	key := [3]int{hard, boolToInt(hasAce), numCards}
+	if p.rules.CharlieCards() == 0 {
+		// The number of cards only matters for a Charlie
+		key[2] = 0
+	}
+	if ev, exists := p.hitEVs[key]; exists {
+		return ev
+	}
+
+	ev := 0.0
+	for value := 1; value <= 10; value++ {
+		newHard := hard + value
+		newHasAce := hasAce || value == 1
+		total := handTotal(newHard, newHasAce)
+
+		valueEV := p.standEV(total, numCards+1)
+		if total < 21 && !p.isCharlie(numCards+1) {
+			valueEV = max(valueEV, p.hitEV(newHard, newHasAce, numCards+1))
+		}
+		ev += p.probabilities[value] * valueEV
+	}
+
+	p.hitEVs[key] = ev
+	return ev
+}
+
+// doubleEV returns the EV of doubling down on the hand in original bets.
+func (p *playerAnalysis) doubleEV(hard int, hasAce bool, numCards int) float64 {
+	ev := 0.0
+	for value := 1; value <= 10; value++ {
+		total := handTotal(hard+value, hasAce || value == 1)
+		ev += p.probabilities[value] * p.standEV(total, numCards+1)
+	}
+	return 2 * ev
+}
+
+// splitEV returns the EV of splitting the pair in original bets, playing
+// each hand with the best of the actions allowed after the split. Resplitting
+// is not considered.
+func (p *playerAnalysis) splitEV(value int) (float64, error) {
+	ev := 0.0
+	for newValue := 1; newValue <= 10; newValue++ {
+		if p.probabilities[newValue] == 0 {
+			continue
+		}
+
+		hand := analysisHand(p.rules.SplitUnlikeTens(), value, newValue)
+		handActionsAllowed, err := p.rules.GetActionsAllowed(hand.Hand, 2, value == 1)
+		if err != nil {
+			return 0, err
+		}
+
+		hard := value + newValue
+		hasAce := value == 1 || newValue == 1
+		total := handTotal(hard, hasAce)
+
+		handEV := p.standEV(total, 2)
+		if total == 21 && p.rules.BlackjackAfterSplit() {
+			// Two-card 21s after splitting are paid as naturals
+			handEV = payoutValue(p.rules.BlackjackPayout())
+		}
+		if handActionsAllowed[blackjack.Hit] {
+			handEV = max(handEV, p.hitEV(hard, hasAce, 2))
+		}
+		if handActionsAllowed[blackjack.Double] {
+			handEV = max(handEV, p.doubleEV(hard, hasAce, 2))
+		}
+		if handActionsAllowed[blackjack.Surrender] {
+			handEV = max(handEV, -0.5)
+		}
+
+		ev += p.probabilities[newValue] * handEV
+	}
+	return 2 * ev, nil
+}
+
+// isCharlie reports whether the number of cards wins the hand by a Charlie.
+func (p *playerAnalysis) isCharlie(numCards int) bool {
+	return p.rules.CharlieCards() > 0 && numCards >= p.rules.CharlieCards()
+}
+
+// handTotal returns the best total of a hand with the hard total, counting
+// an ace as 11 if it does not bust the hand.
+func handTotal(hard int, hasAce bool) int {
+	if hasAce && hard+10 <= 21 {
+		return hard + 10
+	}
+	return hard
+}
+
+// payoutValue returns the payout as a multiple of the bet.
+func payoutValue(payout blackjack.Payout) float64 {
+	return float64(payout.Numerator) / float64(payout.Denominator)
+}
+
+// analysisCard returns a card of the value, 1 for an ace.
+func analysisCard(value int) core.Card {
+	if value == 1 {
+		return core.Card{Rank: core.Ace}
+	}
+	return core.Card{Rank: core.Rank(value)}
+}
+
+// analysisHand returns a player hand of the two card values.
+func analysisHand(splitUnlikeTens bool, first, second int) *person.PlayerHand {
+	hand := person.NewPlayerHand(splitUnlikeTens)
+	hand.AddCard(analysisCard(first))
+	hand.AddCard(analysisCard(second))
+	return hand
+}
+
+// valueString returns the card value as in strategy charts, "2" to "10" or
+// "A".
+func valueString(value int) string {
+	return analysisCard(value).ValueString()
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
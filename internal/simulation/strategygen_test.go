@@ -0,0 +1,53 @@
+package simulation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jljl1337/blackjack-simulator/internal/blackjack"
+)
+
+func TestGenerateStrategyMatchesBuiltInCharts(t *testing.T) {
+	for _, dealerHitsSoft17 := range []bool{false, true} {
+		for _, doubleAfterSplit := range []bool{false, true} {
+			for _, surrenderRule := range []SurrenderRule{SurrenderNone, SurrenderLate} {
+				name := blackjack.ChartName(6, dealerHitsSoft17, doubleAfterSplit, surrenderRule != SurrenderNone)
+
+				t.Run(name, func(t *testing.T) {
+					outputFile := filepath.Join(t.TempDir(), "strategy.csv")
+					simulator := &Simulator{
+						numDecks:   6,
+						numWorkers: 2,
+						rules: NewRules(RulesOptions{
+							DoubleAfterSplit: doubleAfterSplit,
+							MaxNumHands:      4,
+							SurrenderRule:    surrenderRule,
+							DealerHitsSoft17: dealerHitsSoft17,
+							BlackjackPayout:  blackjack.ThreeToTwo,
+							HoleCardRule:     HoleCardPeek,
+						}),
+						strategyOutputFile: outputFile,
+					}
+
+					if err := simulator.generateStrategy(); err != nil {
+						t.Fatal(err)
+					}
+
+					generated, err := os.ReadFile(outputFile)
+					if err != nil {
+						t.Fatal(err)
+					}
+					builtIn, err := os.ReadFile(filepath.Join("..", "blackjack", "charts", name+".csv"))
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if string(generated) != string(builtIn) {
+						t.Errorf("generated strategy differs from the built-in chart:\n%s\nwant:\n%s", generated, builtIn)
+					}
+				})
+			}
+		}
+	}
+}